@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var analyzer_templates = []string{
+	`// Package glversion provides Analyzer reporting the GL version and
+// extensions required by calls into package %s.
+package glversion
+
+// generate by genglgo[https://github.com/vizee/genglgo]
+// target: %s, updated at: %s
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	API_NAME = %q
+	PROFILE  = %q
+	PACKAGE  = %q
+)
+`,
+	`
+// Requirements is the result of Analyzer for a package, Version is the
+// highest version required by its calls and Extensions lists the extensions
+// required by calls of commands which are in no version, alternatives are
+// separated by "|".
+type Requirements struct {
+	Version    string
+	Extensions []string
+}
+
+var (
+	target_version    string
+	target_extensions string
+	summary           bool
+)
+
+// Analyzer reports calls of commands unavailable in the target set by flag
+// version and extensions, and the requirements of the package with flag
+// summary.
+var Analyzer = &analysis.Analyzer{
+	Name:       "glversion",
+	Doc:        "report the GL version and extensions required by calls into package " + PACKAGE,
+	Run:        run,
+	ResultType: reflect.TypeOf((*Requirements)(nil)),
+}
+
+func init() {
+	Analyzer.Flags.StringVar(&target_version, "version", "", "target GL version, calls of commands added by later versions are reported")
+	Analyzer.Flags.StringVar(&target_extensions, "extensions", "", "comma separated extensions of the target")
+	Analyzer.Flags.BoolVar(&summary, "summary", false, "report the version and extensions required by every package")
+}
+
+// describe returns the name of version, like "4.2 core".
+func describe(version string) string {
+	if API_NAME == "gl" {
+		return version + " " + PROFILE
+	}
+	return API_NAME + " " + version
+}
+
+// version_less compares versions like 4.10 and 4.9 by major and minor
+// numbers, "" is less than any version.
+func version_less(a string, b string) bool {
+	parse := func(s string) (int, int) {
+		major, minor, _ := strings.Cut(s, ".")
+		x, _ := strconv.Atoi(major)
+		y, _ := strconv.Atoi(minor)
+		return x, y
+	}
+	a_major, a_minor := parse(a)
+	b_major, b_minor := parse(b)
+	return a_major < b_major || a_major == b_major && a_minor < b_minor
+}
+
+// is_gl_package reports whether pkg is the generated package, it is named
+// PACKAGE and declares API_NAME.
+func is_gl_package(pkg *types.Package) bool {
+	if pkg == nil || pkg.Name() != PACKAGE {
+		return false
+	}
+	c, ok := pkg.Scope().Lookup("API_NAME").(*types.Const)
+	return ok && c.Val().String() == strconv.Quote(API_NAME)
+}
+
+// lookup returns the requirement of a function or method of the generated
+// package, methods of Fake don't call GL.
+func lookup(pass *analysis.Pass, obj types.Object) (requirement, bool) {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == pass.Pkg || !is_gl_package(fn.Pkg()) {
+		return requirement{}, false
+	}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if named, ok := t.(*types.Named); ok && named.Obj().Name() == "Fake" {
+			return requirement{}, false
+		}
+	}
+	r, ok := requirements[fn.Name()]
+	return r, ok
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	available := make(map[string]bool)
+	for _, name := range strings.Split(target_extensions, ",") {
+		if name != "" {
+			available[name] = true
+		}
+	}
+	provided := func(r requirement) bool {
+		for _, name := range r.extensions {
+			if available[name] {
+				return true
+			}
+		}
+		return false
+	}
+	result := &Requirements{}
+	extensions := make(map[string]bool)
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			id, ok := node.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			r, ok := lookup(pass, pass.TypesInfo.Uses[id])
+			if !ok {
+				return true
+			}
+			if r.version == "" {
+				extensions[strings.Join(r.extensions, "|")] = true
+				if target_version != "" && !provided(r) {
+					pass.Reportf(id.Pos(), "%s requires %s", id.Name, strings.Join(r.extensions, " or "))
+				}
+				return true
+			}
+			if version_less(result.Version, r.version) {
+				result.Version = r.version
+			}
+			if target_version != "" && version_less(target_version, r.version) && !provided(r) {
+				if len(r.extensions) != 0 {
+					pass.Reportf(id.Pos(), "%s requires %s or %s", id.Name, describe(r.version), strings.Join(r.extensions, " or "))
+				} else {
+					pass.Reportf(id.Pos(), "%s requires %s", id.Name, describe(r.version))
+				}
+			}
+			return true
+		})
+	}
+	for name := range extensions {
+		result.Extensions = append(result.Extensions, name)
+	}
+	sort.Strings(result.Extensions)
+	if summary && len(pass.Files) != 0 && (result.Version != "" || len(result.Extensions) != 0) {
+		var needs []string
+		if result.Version != "" {
+			needs = append(needs, describe(result.Version))
+		}
+		needs = append(needs, result.Extensions...)
+		pass.Reportf(pass.Files[0].Package, "package %s requires %s", pass.Pkg.Name(), strings.Join(needs, ", "))
+	}
+	return result, nil
+}
+
+// requirement is the version adding a command and the extensions providing
+// it, version is "" if no version adds it.
+type requirement struct {
+	command    string
+	version    string
+	extensions []string
+}
+
+var requirements = map[string]requirement{
+`,
+}
+
+// gen_go_analyzer generates package glversion with Analyzer for package pkg,
+// gonames maps Go functions to commands, added maps commands to versions
+// adding them and provided maps commands to extensions providing them.
+func gen_go_analyzer(api string, profile string, pkg string, target string, updated string, gonames map[string]string, added map[string]string, provided map[string][]string) string {
+	s := fmt.Sprintf(analyzer_templates[0], pkg, target, updated, api, profile, pkg)
+	s += analyzer_templates[1]
+	names := make([]string, 0, len(gonames))
+	for name := range gonames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command := gonames[name]
+		extensions := "nil"
+		if exts := provided[command]; len(exts) != 0 {
+			quoted := make([]string, len(exts))
+			for i, ext := range exts {
+				quoted[i] = strconv.Quote(ext)
+			}
+			extensions = "[]string{" + strings.Join(quoted, ", ") + "}"
+		}
+		s += "\t" + strconv.Quote(name) + ": {" + strconv.Quote(command) + ", " + strconv.Quote(added[command]) + ", " + extensions + "},\n"
+	}
+	s += "}\n"
+	return s
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+var batch_templates = []string{
+	`
+/*
+#include <stdint.h>
+#include <string.h>
+#include "glgo.h"
+
+static float glgo_word_f(uint64_t w) {
+	uint32_t u = (uint32_t)w;
+	float f;
+	memcpy(&f, &u, sizeof(f));
+	return f;
+}
+
+static double glgo_word_d(uint64_t w) {
+	double d;
+	memcpy(&d, &w, sizeof(d));
+	return d;
+}
+
+static void glgo_batch_run(void **procs, const uint64_t *w, size_t n) {
+	const uint64_t *end = w + n;
+	while (w < end) {
+		switch (*w++) {
+`,
+	`		}
+	}
+}
+*/
+import "C"
+`,
+	`
+// CommandBuffer records calls of batched commands and calls them by Submit
+// at once, errors of batched commands are not checked and calls are not
+// traced.
+type CommandBuffer struct {
+	ctx   *Context
+	words []uint64
+}
+
+// NewCommandBuffer creates a CommandBuffer calling commands of the default
+// context.
+func NewCommandBuffer() *CommandBuffer {
+	return &CommandBuffer{ctx: &default_context}
+}
+
+// NewCommandBuffer creates a CommandBuffer calling commands of ctx.
+func (ctx *Context) NewCommandBuffer() *CommandBuffer {
+	return &CommandBuffer{ctx: ctx}
+}
+
+// Reset discards recorded calls.
+func (buf_ *CommandBuffer) Reset() {
+	buf_.words = buf_.words[:0]
+}
+
+func bool_word(b Boolean) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+`,
+}
+
+// is_batchable reports whether calls of command can be recorded, commands
+// with results or pointer params are not.
+func is_batchable(info command_info) bool {
+	if info.rettype != "void" {
+		return false
+	}
+	for _, p := range info.params {
+		if nocgo_class(param_gotype(p)) == 'p' {
+			return false
+		}
+	}
+	return true
+}
+
+// batch_commands selects batched commands of commands_list by names, "all"
+// selects every batchable command.
+func batch_commands(commands_list []string, commands_map map[string]command_info, names []string) ([]string, error) {
+	if len(names) == 1 && names[0] == "all" {
+		var batch []string
+		for _, command := range commands_list {
+			if is_batchable(commands_map[command]) {
+				batch = append(batch, command)
+			}
+		}
+		return batch, nil
+	}
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		info, ok := commands_map[name]
+		if !ok {
+			return nil, errors.New("unknown batched command: " + name)
+		}
+		if !is_batchable(info) {
+			return nil, errors.New("command can't be batched: " + name)
+		}
+		selected[name] = true
+	}
+	var batch []string
+	for _, command := range commands_list {
+		if selected[command] {
+			batch = append(batch, command)
+		}
+	}
+	return batch, nil
+}
+
+// gen_go_word converts Go argument name to a word of CommandBuffer.
+func gen_go_word(name string, gotype string) string {
+	switch nocgo_class(gotype) {
+	case 'f':
+		return "uint64(math.Float32bits(float32(" + name + ")))"
+	case 'd':
+		return "math.Float64bits(float64(" + name + "))"
+	}
+	if gotype == "Boolean" {
+		return "bool_word(" + name + ")"
+	}
+	return "uint64(" + name + ")"
+}
+
+// gen_go_batch generates CommandBuffer and returns it with the methods
+// recording every command of batch, which are placed by the caller. cgo
+// buffers are submitted by one call of a C loop over the commands cached by
+// load_batch, nocgo buffers record the replay stubs and the commands, which
+// tramp_batch calls in one call.
+func gen_go_batch(batch []string, commands_map map[string]command_info, nocgo bool) (string, map[string]string) {
+	s := ""
+	if !nocgo {
+		s += batch_templates[0]
+		for op, command := range batch {
+			info := commands_map[command]
+			s += "\t\tcase " + strconv.Itoa(op) + ":\n"
+			s += "\t\t\t((_glgo_t_" + c_dispatch_name(info) + ")procs[" + strconv.Itoa(op) + "])("
+			for i, p := range info.params {
+				if i != 0 {
+					s += ", "
+				}
+				word := "w[" + strconv.Itoa(i) + "]"
+				switch nocgo_class(param_gotype(p)) {
+				case 'f':
+					s += "glgo_word_f(" + word + ")"
+				case 'd':
+					s += "glgo_word_d(" + word + ")"
+				default:
+					s += "(" + p.ptype + ")" + word
+				}
+			}
+			s += ");\n"
+			s += "\t\t\tw += " + strconv.Itoa(len(info.params)) + ";\n"
+			s += "\t\t\tbreak;\n"
+		}
+		s += batch_templates[1]
+	}
+	s += batch_templates[2]
+	s += "\n// Submit calls recorded commands in order and resets the buffer.\n"
+	s += "func (buf_ *CommandBuffer) Submit() {\n"
+	s += "\tif len(buf_.words) == 0 {\n\t\treturn\n\t}\n"
+	if nocgo {
+		// records hold the replay stubs and the commands, tramp_batch
+		// calls them in one call
+		s += "\tcall_batch(buf_.words)\n"
+	} else {
+		s += "\tC.glgo_batch_run(&buf_.ctx.batch_procs[0], (*C.uint64_t)(unsafe.Pointer(&buf_.words[0])), C.size_t(len(buf_.words)))\n"
+	}
+	s += "\tbuf_.words = buf_.words[:0]\n"
+	s += "}\n"
+	if !nocgo {
+		s += "\n// load_batch caches the commands of ops of CommandBuffer when ctx is\n"
+		s += "// loaded.\n"
+		s += "func (ctx *Context) load_batch() {\n"
+		s += "\tctx.batch_procs = [...]unsafe.Pointer{\n"
+		for _, command := range batch {
+			s += "\t\tunsafe.Pointer(ctx.p_" + command + "),\n"
+		}
+		s += "\t}\n"
+		s += "}\n"
+	}
+	methods := make(map[string]string, len(batch))
+	for op, command := range batch {
+		info := commands_map[command]
+		m := "\nfunc (buf_ *CommandBuffer) " + kill_gl(command) + "("
+		words := "buf_.words = append(buf_.words, " + strconv.Itoa(op)
+		if nocgo {
+			words = "buf_.words = append(buf_.words, uint64(replay_" + nocgo_signature(info) + "_addr), uint64(buf_.ctx.p_" + command + ")"
+		}
+		for i, p := range info.params {
+			if i != 0 {
+				m += ", "
+			}
+			name := save_go_kw(p.name)
+			m += name + " " + param_gotype(p)
+			if p.enum_iface {
+				words += ", " + gen_go_word(name+".enum()", "Enum")
+				continue
+			}
+			words += ", " + gen_go_word(name, param_gotype(p))
+		}
+		m += ") {\n"
+		m += "\t" + words + ")\n"
+		m += "}\n"
+		methods[command] = m
+	}
+	return s, methods
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// bench_extensions returns the names of the first n ARB, EXT and NV
+// extensions supported by api and profile.
+func bench_extensions(registry *glxml_registry, api string, profile string, n int) []string {
+	var names []string
+	for _, extension := range registry.extensions.extension {
+		if len(names) == n {
+			break
+		}
+		if !is_supported_api(extension.supported, api, profile) {
+			continue
+		}
+		if strings.HasPrefix(extension.name, "GL_ARB_") || strings.HasPrefix(extension.name, "GL_EXT_") || strings.HasPrefix(extension.name, "GL_NV_") {
+			names = append(names, extension.name)
+		}
+	}
+	return names
+}
+
+// bench_schemes are the ways benchmarked packages call commands, cgo with
+// a dispatch helper per command is the scheme before helpers were shared by
+// C signatures.
+var bench_schemes = []struct {
+	name        string
+	backend     string
+	per_command bool
+}{
+	{"cgo", "cgo", false},
+	{"cgo-per-command", "cgo", true},
+	{"nocgo", "nocgo", false},
+}
+
+// BenchmarkBuild measures the build of a package of gl compatibility 4.5
+// with 150 extensions, only the generated package is rebuilt by each
+// iteration.
+func BenchmarkBuild(b *testing.B) {
+	for _, scheme := range bench_schemes {
+		b.Run(scheme.name, func(b *testing.B) {
+			env := []string{"CGO_ENABLED=1"}
+			if scheme.backend == "nocgo" {
+				if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+					b.Skip("nocgo backend isn't supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+				}
+				env = []string{"CGO_ENABLED=0"}
+			} else if _, err := exec.LookPath("gcc"); err != nil {
+				b.Skip("gcc not found")
+			}
+			root := gen_test_module(b, []*target{{
+				api:        "gl",
+				profile:    "compatibility",
+				version:    "4.5",
+				extensions: bench_extensions(load_test_registry(b), "gl", "compatibility", 150),
+				output:     "gl",
+				backend:    scheme.backend,
+
+				dispatch_per_command: scheme.per_command,
+			}}, map[string]string{})
+			// dependencies of the package are built once
+			if out, err := go_command(root, env, "build", "./gl"); err != nil {
+				b.Fatalf("%v\n%s", err, out)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// a changed file invalidates the cached build of the package
+				b.StopTimer()
+				src := fmt.Sprintf("package gl\n\nconst benchmark_iteration = %d\n", i)
+				if err := os.WriteFile(filepath.Join(root, "gl", "iteration.go"), []byte(src), 0664); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				if out, err := go_command(root, env, "build", "./gl"); err != nil {
+					b.Fatalf("%v\n%s", err, out)
+				}
+			}
+		})
+	}
+}
+
+// stub_benchmarks are benchmarks run by BenchmarkCall, each op calls
+// commands once, CommandBuffer submits 1000 recorded calls at once.
+const stub_benchmarks = `package call
+
+import (
+	"testing"
+
+	"gltest/gl"
+)
+
+func init() {
+	if gl.Init() != 0 {
+		panic("Init failed")
+	}
+}
+
+func BenchmarkCall(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gl.GetError()
+		gl.Uniform4f(0, 1, 2, 3, 4)
+	}
+}
+
+func BenchmarkViewport(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gl.Viewport(0, 0, 640, 480)
+	}
+}
+
+func BenchmarkCommandBuffer(b *testing.B) {
+	buf := gl.NewCommandBuffer()
+	for i := 0; i < b.N; i++ {
+		buf.Viewport(0, 0, 640, 480)
+		if i%1000 == 999 {
+			buf.Submit()
+		}
+	}
+	buf.Submit()
+}
+`
+
+// BenchmarkCall measures calls of a package of gl core 4.5 loaded from the
+// stub library: glGetError and glUniform4f, glViewport, and glViewport
+// recorded by CommandBuffer. The calls run in a test binary of the
+// generated package, whose ns/op is reported.
+func BenchmarkCall(b *testing.B) {
+	if runtime.GOOS != "linux" {
+		b.Skip("stub library isn't supported on " + runtime.GOOS)
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		b.Skip("gcc not found")
+	}
+	for _, scheme := range bench_schemes {
+		env := []string{"CGO_ENABLED=1"}
+		if scheme.backend == "nocgo" {
+			if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+				continue
+			}
+			env = []string{"CGO_ENABLED=0"}
+		}
+		root := gen_test_module(b, []*target{{
+			api:     "gl",
+			profile: "core",
+			version: "4.5",
+			output:  "gl",
+			backend: scheme.backend,
+			batch:   []string{"glViewport"},
+
+			dispatch_per_command: scheme.per_command,
+		}}, map[string]string{
+			"call/call_test.go": stub_benchmarks,
+		})
+		libs := filepath.Join(root, "libs")
+		if err := os.Mkdir(libs, 0775); err != nil {
+			b.Fatal(err)
+		}
+		build_stub_library(b, libs, "4.5 stub", "libGL.so", "libGL.so.1")
+		env = append(env, "CGO_LDFLAGS=-L"+libs)
+		bin := filepath.Join(root, "call.test")
+		if out, err := go_command(root, env, "test", "-c", "-o", bin, "./call"); err != nil {
+			b.Fatalf("%v\n%s", err, out)
+		}
+		for _, name := range []string{"Call", "Viewport", "CommandBuffer"} {
+			b.Run(scheme.name+"/"+name, func(b *testing.B) {
+				b.ResetTimer()
+				cmd := exec.Command(bin, "-test.run=NONE", "-test.bench=^Benchmark"+name+"$", "-test.benchtime="+strconv.Itoa(b.N)+"x")
+				cmd.Env = append(os.Environ(), "LD_LIBRARY_PATH="+libs)
+				out, err := cmd.CombinedOutput()
+				if err != nil {
+					b.Fatalf("%v\n%s", err, out)
+				}
+				b.StopTimer()
+				b.ReportMetric(bench_ns_per_op(b, string(out)), "ns/op")
+			})
+		}
+	}
+}
+
+// bench_ns_per_op returns ns/op of the benchmark result in out.
+func bench_ns_per_op(b *testing.B, out string) float64 {
+	b.Helper()
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i := 1; i < len(fields); i++ {
+			if fields[i] == "ns/op" {
+				ns, err := strconv.ParseFloat(fields[i-1], 64)
+				if err != nil {
+					b.Fatal(err)
+				}
+				return ns
+			}
+		}
+	}
+	b.Fatalf("no ns/op in benchmark result:\n%s", out)
+	return 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type config_target struct {
+	API        string   `json:"api"`
+	Profile    string   `json:"profile"`
+	Version    string   `json:"version"`
+	MinVersion string   `json:"min_version"`
+	Extensions []string `json:"extensions"`
+	Package    string   `json:"package"`
+	Output     string   `json:"output"`
+
+	TypedEnums    bool     `json:"typed_enums"`
+	RawEnumGroups []string `json:"raw_enum_groups"`
+	Slices        bool     `json:"slices"`
+	Strings       bool     `json:"strings"`
+	Debug         bool     `json:"debug"`
+	Trace         bool     `json:"trace"`
+	EnumStrings   bool     `json:"enum_strings"`
+	Backend       string   `json:"backend"`
+	Fake          bool     `json:"fake"`
+	NoLoader      bool     `json:"no_loader"`
+	Batch         []string `json:"batch"`
+	Analyzer      bool     `json:"analyzer"`
+	UsedBy        string   `json:"used_by"`
+	Fakecgo       string   `json:"fakecgo"`
+}
+
+type config struct {
+	Input   string          `json:"input"`
+	Targets []config_target `json:"targets"`
+}
+
+// load_config reads genglgo.json, relative paths in it are resolved
+// against the directory of the config file.
+func load_config(path string) (string, []*target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	var conf config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return "", nil, err
+	}
+	if len(conf.Targets) == 0 {
+		return "", nil, errors.New("no target in " + path)
+	}
+	dir := filepath.Dir(path)
+	var inputs []string
+	for _, input := range strings.Split(conf.Input, ",") {
+		if input != "" && !filepath.IsAbs(input) {
+			input = filepath.Join(dir, input)
+		}
+		inputs = append(inputs, input)
+	}
+	input := strings.Join(inputs, ",")
+	targets := make([]*target, 0, len(conf.Targets))
+	seen := make(map[string]bool, len(conf.Targets))
+	for _, ct := range conf.Targets {
+		t := &target{
+			api:         ct.API,
+			profile:     ct.Profile,
+			version:     ct.Version,
+			min_version: ct.MinVersion,
+			extensions:  ct.Extensions,
+			pkg:         ct.Package,
+			output:      ct.Output,
+
+			typed_enums:     ct.TypedEnums,
+			raw_enum_groups: ct.RawEnumGroups,
+			slices:          ct.Slices,
+			strings:         ct.Strings,
+			debug:           ct.Debug,
+			trace:           ct.Trace,
+			enum_strings:    ct.EnumStrings,
+			backend:         ct.Backend,
+			fake:            ct.Fake,
+			no_loader:       ct.NoLoader,
+			batch:           ct.Batch,
+			analyzer:        ct.Analyzer,
+			used_by:         ct.UsedBy,
+			fakecgo:         ct.Fakecgo,
+		}
+		if t.api == "" {
+			t.api = "gl"
+		}
+		if t.output == "" {
+			return "", nil, errors.New("target without output in " + path)
+		}
+		t.output = output_dir(t.output)
+		if !filepath.IsAbs(t.output) {
+			t.output = filepath.Join(dir, t.output)
+		}
+		if t.fakecgo != "" && !filepath.IsAbs(t.fakecgo) {
+			t.fakecgo = filepath.Join(dir, t.fakecgo)
+		}
+		if t.used_by != "" && !filepath.IsAbs(t.used_by) {
+			t.used_by = filepath.Join(dir, t.used_by)
+		}
+		if t.pkg == "" {
+			t.pkg = filepath.Base(t.output)
+		}
+		if seen[t.output] {
+			return "", nil, errors.New("duplicate output: " + ct.Output)
+		}
+		seen[t.output] = true
+		targets = append(targets, t)
+	}
+	return input, targets, nil
+}
+
+// output_dir returns the directory of the package generated at output, an
+// output ending with .go is a file in it like the former default gl/gl.go.
+func output_dir(output string) string {
+	if strings.HasSuffix(output, ".go") {
+		return filepath.Dir(output)
+	}
+	return output
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var context_templates = []string{
+	`
+import (
+	"errors"
+)
+`,
+	`
+// Context holds the function table of commands loaded from a GL context,
+// its methods call the commands of that context. Package functions call
+// the commands loaded by Init or InitWithProcAddr.
+type Context struct {
+`,
+	`
+var default_context Context
+
+// NewContext creates a Context with commands loaded by getProcAddress, like
+// glXGetProcAddress or eglGetProcAddress called with the context current.
+func NewContext(getProcAddress func(name string) unsafe.Pointer) (*Context, error) {
+	ctx := new(Context)
+	if err := ctx.missing(ctx.load(getProcAddress)); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// InitWithProcAddr loads commands of the package functions by
+// getProcAddress, like SDL_GL_GetProcAddress or glfwGetProcAddress.
+func InitWithProcAddr(getProcAddress func(name string) unsafe.Pointer) error {
+	return default_context.missing(default_context.load(getProcAddress))
+}
+
+// InitWithProcAddrC is like InitWithProcAddr, but getProcAddress is a C
+// function void *(*)(const char *name) called without calling Go.
+func InitWithProcAddrC(getProcAddress unsafe.Pointer) error {
+	return default_context.missing(default_context.load_c(getProcAddress))
+}
+
+// missing returns the error of the missing command i returned by load.
+func (ctx *Context) missing(i int) error {
+	if i == 0 {
+		return nil
+	}
+	return errors.New(ctx.procs()[i-1].name + " is not available")
+}
+
+// load loads commands by getProcAddress, it returns the result of check.
+func (ctx *Context) load(getProcAddress func(name string) unsafe.Pointer) int {
+	procs := ctx.procs()
+	for _, proc := range procs {
+		*proc.p = %s(getProcAddress(proc.name))
+	}
+	return ctx.check(procs)
+}
+`,
+	`
+// load_c is like load, but getProcAddress is a C function called for all
+// commands in one cgo call.
+func (ctx *Context) load_c(getProcAddress unsafe.Pointer) int {
+	procs := ctx.procs()
+	var names []byte
+	for _, proc := range procs {
+		names = append(names, proc.name...)
+		names = append(names, 0)
+	}
+	ptrs := make([]unsafe.Pointer, len(procs))
+	C.glgo_load_procs(getProcAddress, (*C.char)(unsafe.Pointer(&names[0])), &ptrs[0], C.int(len(procs)))
+	for i, proc := range procs {
+		*proc.p = (*[0]byte)(ptrs[i])
+	}
+	return ctx.check(procs)
+}
+`,
+	`
+// load_c is like load, but getProcAddress is a C function.
+func (ctx *Context) load_c(getProcAddress unsafe.Pointer) int {
+	return ctx.load(func(name string) unsafe.Pointer {
+		return call_p_p(uintptr(getProcAddress), unsafe.Pointer(c_string(name)))
+	})
+}
+`,
+	`
+// check returns 1 + the index of the first missing command, or 0.
+func (ctx *Context) check(procs []proc) int {
+	for i, proc := range procs {
+		if *proc.p == %s {
+			return i + 1
+		}
+	}
+	return 0
+}
+`,
+	`
+// Has reports the versions supported by the context of the package
+// functions.
+var Has = &default_context.Has
+
+// Version returns the version of the context of the package functions.
+func Version() (major, minor int) {
+	return default_context.Version()
+}
+
+// Version returns the version reported by GL_VERSION of the context, it is
+// 0, 0 if the version can't be parsed.
+func (ctx *Context) Version() (major, minor int) {
+	return ctx.major, ctx.minor
+}
+
+// supports reports whether the context is of version major.minor or later.
+func (ctx *Context) supports(major, minor int) bool {
+	return ctx.major > major || ctx.major == major && ctx.minor >= minor
+}
+
+// parse_version parses the version of strings like "4.6.0 NVIDIA 550.54",
+// "OpenGL ES 3.2 Mesa 23.2.1" and "OpenGL ES-CM 1.1".
+func parse_version(s string) (major, minor int) {
+	i := 0
+	for i < len(s) && (s[i] < '0' || s[i] > '9') {
+		i++
+	}
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		major = major*10 + int(s[i]-'0')
+	}
+	if i == len(s) || s[i] != '.' {
+		return 0, 0
+	}
+	for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		minor = minor*10 + int(s[i]-'0')
+	}
+	return major, minor
+}
+
+// check detects the version of the context, it returns 1 + the index of
+// the first missing command of a supported version, or 0. Commands of later
+// versions and of extensions stay unset if missing, all commands of
+// versions are required if the version can't be detected.
+func (ctx *Context) check(procs []proc) int {
+	ctx.major, ctx.minor = 0, 0
+	if ctx.p_glGetString != %[1]s {
+		ctx.major, ctx.minor = parse_version(%[2]s)
+	}
+`,
+}
+
+// context_features are the versions detected by Context: the fields of
+// Features with their versions, the fields of the versions adding commands
+// and the Go expression of GL_VERSION of ctx.
+type context_features struct {
+	fields   []string
+	versions []gl_version
+	commands map[string]string
+	version  string
+}
+
+// gen_go_proc generates the proc of command, with the field of Has of the
+// version adding it if features detects versions.
+func gen_go_proc(command string, features *context_features) string {
+	if features == nil {
+		return "{\"" + command + "\", &ctx.p_" + command + "}"
+	}
+	has := "nil"
+	if field := features.commands[command]; field != "" {
+		has = "&ctx.Has." + field
+	}
+	return "{\"" + command + "\", &ctx.p_" + command + ", " + has + "}"
+}
+
+// gen_go_context generates Context with a field of type ptrtype for every
+// command and its loader, nocgo contexts hold uintptr and cgo contexts hold
+// C function pointers. Commands in since are loaded only if the files of
+// their versions are built, which add them by gen_go_version_procs. Without
+// features all commands are required, else the loader detects the version
+// of the context and requires commands of the versions it supports. cgo
+// contexts cache the commands of batch for CommandBuffer when loaded. The
+// generated code imports context_templates[0].
+func gen_go_context(commands_list []string, since map[string]string, ptrtype string, in_begin bool, features *context_features, batch []string) string {
+	load_c := context_templates[3]
+	zero := "nil"
+	if ptrtype == "uintptr" {
+		load_c = context_templates[4]
+		zero = "0"
+	}
+	s := ""
+	if features != nil {
+		width := 0
+		for _, field := range features.fields {
+			if len(field) > width {
+				width = len(field)
+			}
+		}
+		s += "\n// Features reports the versions supported by a context, a version is\n"
+		s += "// supported if the context is of that version or a later one.\n"
+		s += "type Features struct {\n"
+		for _, field := range features.fields {
+			s += "\t" + field + strings.Repeat(" ", width-len(field)) + " bool\n"
+		}
+		s += "}\n"
+	}
+	s += context_templates[1]
+	for _, command := range commands_list {
+		s += "\tp_" + command + " " + ptrtype + "\n"
+	}
+	if len(batch) != 0 {
+		s += "\n\t// commands of ops of CommandBuffer, set by load_batch\n"
+		s += fmt.Sprintf("\tbatch_procs [%d]unsafe.Pointer\n", len(batch))
+	}
+	if in_begin {
+		s += "\n\t// glGetError is invalid between glBegin and glEnd\n"
+		s += "\tin_begin bool\n"
+	}
+	if features != nil {
+		s += "\n\t// version of the context and the versions it supports\n"
+		s += "\tmajor, minor int\n"
+		s += "\tHas          Features\n"
+	}
+	s += "}\n"
+	convert := ptrtype
+	if strings.HasPrefix(ptrtype, "*") {
+		convert = "(" + ptrtype + ")"
+	}
+	loaders := fmt.Sprintf(context_templates[2], convert)
+	if len(batch) != 0 {
+		loaders += load_c
+		loaders = strings.Replace(loaders, "\treturn ctx.check(procs)\n", "\tctx.load_batch()\n\treturn ctx.check(procs)\n", -1)
+		load_c = ""
+	}
+	s += loaders
+	if features == nil {
+		s += "\ntype proc struct {\n\tname string\n\tp    *" + ptrtype + "\n}\n"
+		s += fmt.Sprintf(context_templates[5], zero)
+	} else {
+		s += "\n// proc is a command with the field of Has of the version adding it, it\n"
+		s += "// is nil for commands of extensions.\n"
+		s += "type proc struct {\n\tname string\n\tp    *" + ptrtype + "\n\thas  *bool\n}\n"
+		s += fmt.Sprintf(context_templates[6], zero, features.version)
+		for i, field := range features.fields {
+			v := features.versions[i]
+			s += fmt.Sprintf("\tctx.Has.%s = ctx.supports(%d, %d)\n", field, v.major, v.minor)
+		}
+		s += "\tfor i, proc := range procs {\n"
+		s += "\t\tif *proc.p == " + zero + " && proc.has != nil && (*proc.has || ctx.major == 0) {\n"
+		s += "\t\t\treturn i + 1\n"
+		s += "\t\t}\n"
+		s += "\t}\n"
+		s += "\treturn 0\n"
+		s += "}\n"
+	}
+	if len(since) == 0 {
+		s += "\nfunc (ctx *Context) procs() []proc {\n\treturn []proc{\n"
+		for _, command := range commands_list {
+			s += "\t\t" + gen_go_proc(command, features) + ",\n"
+		}
+		s += "\t}\n}\n"
+		return s + load_c
+	}
+	s += "\n// version_procs holds procs of commands added by the versions selected\n"
+	s += "// by build tags.\n"
+	s += "var version_procs []func(ctx *Context) []proc\n"
+	s += "\nfunc (ctx *Context) procs() []proc {\n\tprocs := []proc{\n"
+	for _, command := range commands_list {
+		if since[command] == "" {
+			s += "\t\t" + gen_go_proc(command, features) + ",\n"
+		}
+	}
+	s += "\t}\n"
+	s += "\tfor _, f := range version_procs {\n"
+	s += "\t\tprocs = append(procs, f(ctx)...)\n"
+	s += "\t}\n"
+	s += "\treturn procs\n}\n"
+	return s + load_c
+}
+
+// gen_go_version_procs generates an init function adding procs of commands
+// to version_procs.
+func gen_go_version_procs(commands []string, features *context_features) string {
+	if len(commands) == 0 {
+		return ""
+	}
+	s := "\nfunc init() {\n"
+	s += "\tversion_procs = append(version_procs, func(ctx *Context) []proc {\n"
+	s += "\t\treturn []proc{\n"
+	for _, command := range commands {
+		s += "\t\t\t" + gen_go_proc(command, features) + ",\n"
+	}
+	s += "\t\t}\n"
+	s += "\t})\n"
+	s += "}\n"
+	return s
+}
+
+// gen_go_default generates package functions calling the methods funcs of
+// the default context, they have the doc comments of funcs.
+func gen_go_default(funcs []go_func_decl) string {
+	s := ""
+	for _, f := range funcs {
+		s += "\n" + f.doc + gen_go_forward("", "default_context.", []go_func_decl{f})[1:]
+	}
+	return s
+}
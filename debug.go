@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+)
+
+var debug_templates = []string{
+	`
+import (
+	"fmt"
+)
+
+// Error describes a GL error reported by glGetError after a command.
+type Error struct {
+	Command string
+	Args    []interface{}
+	Code    Enum
+}
+
+func (e *Error) Error() string {
+	s := e.Command + "("
+	for i, arg := range e.Args {
+		if i != 0 {
+			s += ", "
+		}
+		s += fmt.Sprint(arg)
+	}
+	return s + "): " + error_name(e.Code)
+}
+
+// ErrorHandler is called with the failing command when the package is built
+// with tag gldebug, it panics by default.
+var ErrorHandler = func(err *Error) {
+	panic(err)
+}
+`,
+	`
+func (ctx *Context) check_error(command string, args ...interface{}) {
+	// glGetError keeps failing after the context is lost or without
+	// current context, so at most one error of each code is reported
+	for i := 0; i < 8; i++ {
+		code := Enum(C.glgo_GLenum(ctx.p_glGetError))
+		if code == 0 {
+			break
+		}
+		ErrorHandler(&Error{
+			Command: command,
+			Args:    args,
+			Code:    code,
+		})
+		// GL_CONTEXT_LOST
+		if code == 0x0507 {
+			break
+		}
+	}
+}
+`,
+	`
+func (ctx *Context) check_error(command string, args ...interface{}) {
+	if ctx.in_begin {
+		return
+	}
+	// glGetError keeps failing after the context is lost or without
+	// current context, so at most one error of each code is reported
+	for i := 0; i < 8; i++ {
+		code := Enum(C.glgo_GLenum(ctx.p_glGetError))
+		if code == 0 {
+			break
+		}
+		ErrorHandler(&Error{
+			Command: command,
+			Args:    args,
+			Code:    code,
+		})
+		// GL_CONTEXT_LOST
+		if code == 0x0507 {
+			break
+		}
+	}
+}
+`,
+}
+
+var gl_error_list = [...]string{
+	"GL_INVALID_ENUM",
+	"GL_INVALID_VALUE",
+	"GL_INVALID_OPERATION",
+	"GL_STACK_OVERFLOW",
+	"GL_STACK_UNDERFLOW",
+	"GL_OUT_OF_MEMORY",
+	"GL_INVALID_FRAMEBUFFER_OPERATION",
+	"GL_CONTEXT_LOST",
+}
+
+// gen_go_debug_call checks errors after calling command.
+func gen_go_debug_call(command string, info command_info) string {
+	switch command {
+	case "glGetError":
+		return ""
+	case "glBegin":
+		return "\tctx.in_begin = true\n"
+	}
+	args := []string{"\"" + command + "\""}
+	for _, p := range info.params {
+		args = append(args, save_go_kw(p.name))
+	}
+	s := "\tctx.check_error(" + strings.Join(args, ", ") + ")\n"
+	if command == "glEnd" {
+		s = "\tctx.in_begin = false\n" + s
+	}
+	return s
+}
+
+func gen_go_debug_check(has_begin bool, nocgo bool) string {
+	s := debug_templates[1]
+	if has_begin {
+		s = debug_templates[2]
+	}
+	if nocgo {
+		s = strings.Replace(s, "C.glgo_GLenum(ctx.p_glGetError)", "call_i(ctx.p_glGetError)", 1)
+	}
+	return s
+}
+
+func gen_go_debug_error(is_enums map[string]bool) string {
+	s := debug_templates[0]
+	s += "\nfunc error_name(code Enum) string {\n"
+	s += "\tswitch code {\n"
+	for _, name := range gl_error_list {
+		if is_enums[name] {
+			s += "\tcase Enum(" + kill_gl(name) + "):\n"
+			s += "\t\treturn \"" + name + "\"\n"
+		}
+	}
+	s += "\t}\n"
+	s += "\treturn fmt.Sprintf(\"0x%04X\", uint32(code))\n"
+	s += "}\n"
+	return s
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+)
+
+// command_doc holds registry info of a command which isn't in command_info.
+type command_doc struct {
+	required   []string
+	removed    []string
+	extensions []string
+	alias      string
+	deprecated string
+}
+
+// index_core_removals maps names of commands and enums removed from the core
+// profile of api to the feature removing them, names required again by a
+// later feature of the core profile are not removed.
+func index_core_removals(registry *glxml_registry, api string) map[string]string {
+	removals := make(map[string]string)
+	for _, feature := range registry.feature {
+		if !is_same_api(api, feature.api) {
+			continue
+		}
+		for _, require := range feature.require {
+			if require.profile != "" && require.profile != "core" {
+				continue
+			}
+			for _, command := range require.command {
+				delete(removals, command.name)
+			}
+			for _, enum := range require.enum {
+				delete(removals, enum.name)
+			}
+		}
+		for _, remove := range feature.remove {
+			if remove.profile != "core" {
+				continue
+			}
+			for _, command := range remove.command {
+				removals[command.name] = feature.name
+			}
+			for _, enum := range remove.enum {
+				removals[enum.name] = feature.name
+			}
+		}
+	}
+	return removals
+}
+
+// gen_go_deprecated generates the Deprecated paragraph of a name removed
+// from the core profile by feature.
+func gen_go_deprecated(feature string) string {
+	return "// Deprecated: removed from the core profile by " + feature + ".\n"
+}
+
+// index_command_docs collects features requiring and removing commands of
+// api, extensions supporting api and profile which provide them, their
+// aliases and the features of removals removing them from the core profile.
+func index_command_docs(registry *glxml_registry, api string, profile string, removals map[string]string) map[string]*command_doc {
+	docs := make(map[string]*command_doc)
+	doc := func(name string) *command_doc {
+		d, ok := docs[name]
+		if !ok {
+			d = &command_doc{}
+			docs[name] = d
+		}
+		return d
+	}
+	with_profile := func(name string, profile string) string {
+		if profile != "" {
+			return name + " (" + profile + ")"
+		}
+		return name
+	}
+	for _, feature := range registry.feature {
+		if !is_same_api(api, feature.api) {
+			continue
+		}
+		for _, require := range feature.require {
+			for _, command := range require.command {
+				d := doc(command.name)
+				d.required = append(d.required, with_profile(feature.name, require.profile))
+			}
+		}
+		for _, remove := range feature.remove {
+			for _, command := range remove.command {
+				d := doc(command.name)
+				d.removed = append(d.removed, with_profile(feature.name, remove.profile))
+			}
+		}
+	}
+	for _, extension := range registry.extensions.extension {
+		if !is_supported_api(extension.supported, api, profile) {
+			continue
+		}
+		for _, require := range extension.require {
+			if require.api != "" && !is_same_api(api, require.api) {
+				continue
+			}
+			for _, command := range require.command {
+				d := doc(command.name)
+				d.extensions = append(d.extensions, extension.name)
+			}
+		}
+	}
+	for _, command := range registry.commands.command {
+		if command.alias.name != "" {
+			doc(command.proto.name).alias = command.alias.name
+		}
+	}
+	for name, feature := range removals {
+		doc(name).deprecated = feature
+	}
+	return docs
+}
+
+// gen_go_command_doc generates the doc comment of goname wrapping command
+// with its C prototype and registry info in d, a raw Ptr wrapper refers to
+// helper wrapping it, which is "" for other wrappers.
+func gen_go_command_doc(goname string, helper string, command string, info command_info, d *command_doc) string {
+	params := make([]string, len(info.params))
+	for i, p := range info.params {
+		if strings.HasSuffix(p.ptype, "*") {
+			params[i] = p.ptype + p.name
+		} else {
+			params[i] = p.ptype + " " + p.name
+		}
+	}
+	s := "// " + goname + " calls " + command + ":\n"
+	s += "//\n"
+	s += "//\t" + info.rettype + " " + command + "(" + strings.Join(params, ", ") + ")\n"
+	if helper != "" {
+		s += "//\n// " + helper + " wraps it with Go strings and slices instead of pointers.\n"
+	}
+	if d == nil {
+		d = &command_doc{}
+	}
+	lines := ""
+	if len(d.required) != 0 {
+		lines += "//   - required by " + strings.Join(d.required, ", ") + "\n"
+	}
+	if len(d.removed) != 0 {
+		lines += "//   - removed by " + strings.Join(d.removed, ", ") + "\n"
+	}
+	if len(d.extensions) != 0 {
+		lines += "//   - provided by " + strings.Join(d.extensions, ", ") + "\n"
+	}
+	for _, p := range info.params {
+		var attrs []string
+		if p.group != "" {
+			attrs = append(attrs, "group "+p.group)
+		}
+		if p.len_ != "" {
+			attrs = append(attrs, "len "+p.len_)
+		}
+		if len(attrs) != 0 {
+			lines += "//   - " + p.name + ": " + strings.Join(attrs, ", ") + "\n"
+		}
+	}
+	if info.retgroup != "" {
+		lines += "//   - result: group " + info.retgroup + "\n"
+	}
+	if d.alias != "" {
+		lines += "//   - alias of " + d.alias + "\n"
+	}
+	if lines != "" {
+		s += "//\n// Registry:\n" + lines
+	}
+	if d.deprecated != "" {
+		s += "//\n" + gen_go_deprecated(d.deprecated)
+	}
+	return s
+}
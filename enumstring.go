@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var enum_string_template = `
+import (
+	"fmt"
+)
+
+// EnumString returns the name of enum v, like "GL_COMPILE_STATUS" for 0x8B81.
+// If several enums have value v, names without vendor suffix are preferred.
+func EnumString(v Enum) string {
+	if name, ok := enum_names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", uint32(v))
+}
+
+// EnumStringIn returns the name of enum v in registry group, like
+// EnumStringIn("PrimitiveType", 0) returns "GL_POINTS".
+func EnumStringIn(group string, v Enum) string {
+	if name, ok := enum_group_names[group][v]; ok {
+		return name
+	}
+	return EnumString(v)
+}
+`
+
+// enum_vendor_rank ranks names of enums with the same value, core names
+// come first, then Khronos extensions, EXT and other vendors.
+func enum_vendor_rank(name string, vendors map[string]bool) int {
+	i := strings.LastIndex(name, "_")
+	if i < 0 || !vendors[name[i+1:]] {
+		return 0
+	}
+	switch name[i+1:] {
+	case "ARB", "KHR", "OES":
+		return 1
+	case "EXT":
+		return 2
+	}
+	return 3
+}
+
+type enum_name_value struct {
+	name  string
+	value uint32
+	rank  int
+}
+
+// pick_enum_names maps every value to its preferred name, names are in
+// registry order.
+func pick_enum_names(names []enum_name_value) map[uint32]string {
+	best := make(map[uint32]enum_name_value)
+	for _, e := range names {
+		if b, ok := best[e.value]; !ok || e.rank < b.rank {
+			best[e.value] = e
+		}
+	}
+	m := make(map[uint32]string, len(best))
+	for v, e := range best {
+		m[v] = e.name
+	}
+	return m
+}
+
+func gen_go_enum_map(indent string, m map[uint32]string) string {
+	values := make([]uint32, 0, len(m))
+	for v := range m {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i] < values[j]
+	})
+	s := ""
+	for _, v := range values {
+		s += indent + fmt.Sprintf("0x%04X: %q,\n", v, m[v])
+	}
+	return s
+}
+
+// gen_go_enum_string generates EnumString and EnumStringIn for the selected
+// enums, bitmasks and values not fitting in Enum are left out.
+func gen_go_enum_string(registry *glxml_registry, api string, is_enums map[string]bool) string {
+	vendors := make(map[string]bool)
+	for _, extension := range registry.extensions.extension {
+		if parts := strings.SplitN(extension.name, "_", 3); len(parts) == 3 {
+			vendors[parts[1]] = true
+		}
+	}
+	var all []enum_name_value
+	index := make(map[string]int)
+	for _, enums := range registry.enums {
+		if enums.type_ == "bitmask" {
+			continue
+		}
+		for _, e := range enums.enum {
+			if !is_enums[e.name] || !is_api_member(api, e.api) || e.type_ == "ull" {
+				continue
+			}
+			if _, ok := index[e.name]; ok {
+				continue
+			}
+			v, err := strconv.ParseUint(e.value, 0, 32)
+			if err != nil {
+				continue
+			}
+			index[e.name] = len(all)
+			all = append(all, enum_name_value{
+				name:  e.name,
+				value: uint32(v),
+				rank:  enum_vendor_rank(e.name, vendors),
+			})
+		}
+	}
+	s := enum_string_template
+	s += "\nvar enum_names = map[Enum]string{\n"
+	s += gen_go_enum_map("\t", pick_enum_names(all))
+	s += "}\n"
+	s += "\nvar enum_group_names = map[string]map[Enum]string{\n"
+	for _, group := range registry.groups.group {
+		var names []enum_name_value
+		for _, e := range group.enum {
+			if i, ok := index[e.name]; ok {
+				names = append(names, all[i])
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		s += "\t\"" + group.name + "\": {\n"
+		s += gen_go_enum_map("\t\t", pick_enum_names(names))
+		s += "\t},\n"
+	}
+	s += "}\n"
+	return s
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+var fake_templates = []string{
+	`
+// GL is implemented by Default calling the package functions, by Context
+// and by Fake recording calls for tests.
+type GL interface {
+`,
+	`
+import (
+	"reflect"
+	"sync"
+)
+
+// FakeCall is a call recorded by Fake, Args are the Go arguments of the
+// method.
+type FakeCall struct {
+	Name string
+	Args []interface{}
+}
+
+// Fake implements GL without calling GL, it records calls and returns
+// results queued by Return or computed by handlers set by Handle. Methods
+// return zero values otherwise.
+type Fake struct {
+	mu       sync.Mutex
+	calls    []FakeCall
+	results  map[string][]interface{}
+	handlers map[string]func(args []interface{}) interface{}
+}
+
+var _ GL = (*Fake)(nil)
+
+// Return queues results of following calls of method name, every call
+// returns one of them. results are converted to the result type of the
+// method, so untyped enums like INVALID_ENUM can be returned.
+func (fake_ *Fake) Return(name string, results ...interface{}) {
+	fake_.mu.Lock()
+	defer fake_.mu.Unlock()
+	if fake_.results == nil {
+		fake_.results = make(map[string][]interface{})
+	}
+	fake_.results[name] = append(fake_.results[name], results...)
+}
+
+// Handle sets h to be called by method name when no result is queued, h
+// can fill params pointing to memory and its result is returned like
+// results of Return.
+func (fake_ *Fake) Handle(name string, h func(args []interface{}) interface{}) {
+	fake_.mu.Lock()
+	defer fake_.mu.Unlock()
+	if fake_.handlers == nil {
+		fake_.handlers = make(map[string]func(args []interface{}) interface{})
+	}
+	fake_.handlers[name] = h
+}
+
+// Calls returns all recorded calls.
+func (fake_ *Fake) Calls() []FakeCall {
+	fake_.mu.Lock()
+	defer fake_.mu.Unlock()
+	return append([]FakeCall(nil), fake_.calls...)
+}
+
+// CallsOf returns recorded calls of method name.
+func (fake_ *Fake) CallsOf(name string) []FakeCall {
+	fake_.mu.Lock()
+	defer fake_.mu.Unlock()
+	var calls []FakeCall
+	for _, c := range fake_.calls {
+		if c.Name == name {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset clears recorded calls, queued results and handlers.
+func (fake_ *Fake) Reset() {
+	fake_.mu.Lock()
+	defer fake_.mu.Unlock()
+	fake_.calls = nil
+	fake_.results = nil
+	fake_.handlers = nil
+}
+
+// call records the call and stores its result to ret if ret is not nil.
+func (fake_ *Fake) call(ret interface{}, name string, args ...interface{}) {
+	fake_.mu.Lock()
+	fake_.calls = append(fake_.calls, FakeCall{Name: name, Args: args})
+	var (
+		result interface{}
+		h      func(args []interface{}) interface{}
+	)
+	if queue := fake_.results[name]; len(queue) > 0 {
+		result = queue[0]
+		fake_.results[name] = queue[1:]
+	} else {
+		h = fake_.handlers[name]
+	}
+	fake_.mu.Unlock()
+	if h != nil {
+		result = h(args)
+	}
+	if ret == nil || result == nil {
+		return
+	}
+	v := reflect.ValueOf(ret).Elem()
+	v.Set(reflect.ValueOf(result).Convert(v.Type()))
+}
+`,
+}
+
+// go_func_decl is the signature of an exported function in generated code.
+type go_func_decl struct {
+	doc     string
+	name    string
+	params  string
+	args    []string
+	result  string
+	varargs bool
+}
+
+// parse_go_funcs returns exported functions declared in src with receiver
+// type recv, or without receiver if recv is "", sorted by name.
+func parse_go_funcs(src string, recv string) ([]go_func_decl, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", "package p\n"+src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	text := func(node ast.Node) string {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, node)
+		return buf.String()
+	}
+	var funcs []go_func_decl
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || !fn.Name.IsExported() {
+			continue
+		}
+		if fn.Recv == nil && recv != "" || fn.Recv != nil && text(fn.Recv.List[0].Type) != recv {
+			continue
+		}
+		f := go_func_decl{name: fn.Name.Name}
+		if fn.Doc != nil {
+			for _, c := range fn.Doc.List {
+				f.doc += c.Text + "\n"
+			}
+		}
+		var params []string
+		for _, field := range fn.Type.Params.List {
+			var names []string
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+				f.args = append(f.args, name.Name)
+			}
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				f.varargs = true
+			}
+			params = append(params, strings.Join(names, ", ")+" "+text(field.Type))
+		}
+		f.params = strings.Join(params, ", ")
+		if fn.Type.Results != nil {
+			f.result = text(fn.Type.Results.List[0].Type)
+		}
+		funcs = append(funcs, f)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].name < funcs[j].name
+	})
+	return funcs, nil
+}
+
+// gen_go_iface generates interface GL of funcs and Default implementing it
+// by calling funcs.
+func gen_go_iface(funcs []go_func_decl) string {
+	return gen_go_iface_decl(funcs) + gen_go_default_impl(funcs)
+}
+
+// gen_go_iface_decl generates interface GL of funcs.
+func gen_go_iface_decl(funcs []go_func_decl) string {
+	s := fake_templates[0]
+	for _, f := range funcs {
+		s += "\t" + f.name + "(" + f.params + ") " + f.result + "\n"
+	}
+	return s + "}\n"
+}
+
+// gen_go_default_impl generates Default implementing GL by calling funcs,
+// methods of later versions are added by gen_go_forward.
+func gen_go_default_impl(funcs []go_func_decl) string {
+	s := "\n// Default implements GL by calling the package functions.\n"
+	s += "type Default struct{}\n"
+	s += "\nvar (\n"
+	s += "\t_ GL = Default{}\n"
+	s += "\t_ GL = (*Context)(nil)\n"
+	s += ")\n"
+	return s + gen_go_forward("(Default) ", "", funcs)
+}
+
+// gen_go_forward generates funcs declared with prefix, like a receiver, which
+// call funcs qualified by target.
+func gen_go_forward(prefix string, target string, funcs []go_func_decl) string {
+	s := ""
+	for _, f := range funcs {
+		call := target + f.name + "(" + strings.Join(f.args, ", ")
+		if f.varargs {
+			call += "..."
+		}
+		call += ")"
+		s += "\nfunc " + prefix + f.name + "(" + f.params + ") " + f.result + " {\n"
+		if f.result != "" {
+			s += "\treturn " + call + "\n"
+		} else {
+			s += "\t" + call + "\n"
+		}
+		s += "}\n"
+	}
+	return s
+}
+
+// gen_go_fake generates Fake implementing GL.
+func gen_go_fake(funcs []go_func_decl) string {
+	return fake_templates[1] + gen_go_fake_methods(funcs)
+}
+
+// gen_go_fake_methods generates methods of Fake recording calls of funcs.
+func gen_go_fake_methods(funcs []go_func_decl) string {
+	s := ""
+	for _, f := range funcs {
+		args := ""
+		for _, arg := range f.args {
+			args += ", " + arg
+		}
+		s += "\nfunc (fake_ *Fake) " + f.name + "(" + f.params + ") "
+		if f.result != "" {
+			s += "(ret_ " + f.result + ") {\n"
+			s += "\tfake_.call(&ret_, \"" + f.name + "\"" + args + ")\n"
+			s += "\treturn\n"
+		} else {
+			s += "{\n"
+			s += "\tfake_.call(nil, \"" + f.name + "\"" + args + ")\n"
+		}
+		s += "}\n"
+	}
+	return s
+}
@@ -1,21 +1,28 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"go/format"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
 
 var templates = []string{
-	`// +build windows linux
+	`// +build %s
 
-package gl
+package %s
 
 // generate by genglgo[https://github.com/vizee/genglgo]
+// target: %s, updated at: %s
+// features: %s
 `,
 	`
 import (
@@ -31,6 +38,8 @@ import "C"
 `,
 	`
 /*
+#include "glgo.h"
+
 #if defined(GL_PLATFORM_LINUX)
 #include <GL/glx.h>
 
@@ -57,10 +66,64 @@ static void* _glgo_GetProcAddress(const char* name) {
 #endif
 */
 import "C"
-
+`,
+	`
 /*
+#include <stdlib.h>
+#include <string.h>
+#include "glgo.h"
+
+static void glgo_load_procs(void *get_proc_address, const char *names, void **procs, int n) {
+	void *(*get)(const char *) = (void *(*)(const char *))get_proc_address;
+	int i;
+	for (i = 0; i < n; i++) {
+		procs[i] = get(names);
+		names += strlen(names) + 1;
+	}
+}
+*/
+import "C"
+`,
+	`
+func load_proc(name string) unsafe.Pointer {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C._glgo_GetProcAddress(cname)
+}
+`,
+	`
+// Init loads commands of the package functions from the current GL context,
+// it returns 1 + the index of the first missing command, or 0.
+func Init() int {
+	return default_context.load(load_proc)
+}
+`,
+	`
+const (
+`,
+	`)
+`,
+	`/* generate by genglgo[https://github.com/vizee/genglgo]
+ * target: %s, updated at: %s
+ * features: %s
+ */
+
+#ifndef GLGO_H
+#define GLGO_H
+`,
+	`
+#endif
+`,
+	`
+// #include <stdlib.h>
+// #include "glgo.h"
+import "C"
+`,
+	`
 #if defined(APIENTRY)
 #define GLGO_APIENTRY APIENTRY
+#elif defined(KHRONOS_APIENTRY)
+#define GLGO_APIENTRY KHRONOS_APIENTRY
 #elif defined(_STDCALL_SUPPORTED)
 #define GLGO_APIENTRY __stdcall
 #else
@@ -68,7 +131,7 @@ import "C"
 #endif
 
 #ifndef GLGO_APIENTRYP
-#define GLGO_APIENTRYP APIENTRY *
+#define GLGO_APIENTRYP GLGO_APIENTRY *
 #endif
 
 #ifdef far
@@ -78,52 +141,143 @@ import "C"
 #undef near
 #endif
 
-#define GLGO_COMMAND_DECL(return_type, command, ...) \
-typedef return_type (GLGO_APIENTRYP _glgo_t_##command)(__VA_ARGS__);\
-static _glgo_t_##command _glgo_p_##command = 0;\
-return_type command(__VA_ARGS__)
+#define GLGO_DISPATCH_DECL(return_type, signature, ...) \
+typedef return_type (GLGO_APIENTRYP _glgo_t_##signature)(__VA_ARGS__);\
+static return_type glgo_##signature(_glgo_t_##signature _glgo_p, ##__VA_ARGS__)
 
-#define GLGO_COMMAND_RET(command, ...) \
+#define GLGO_DISPATCH_RET(...) \
 {\
-	return _glgo_p_##command(__VA_ARGS__); \
+	return _glgo_p(__VA_ARGS__); \
 }
 
-#define GLGO_COMMAND_0RET(command, ...) \
+#define GLGO_DISPATCH_0RET(...) \
 {\
-	_glgo_p_##command(__VA_ARGS__); \
+	_glgo_p(__VA_ARGS__); \
 }
-
-#define GLGO_COMMAND_GETPROC(command) \
-	(_glgo_p_##command = (_glgo_t_##command)_glgo_GetProcAddress(#command))
-*/
-import "C"
 `,
 	`
-/*
+//#cgo linux   CFLAGS: -DGL_PLATFORM_LINUX
+//#cgo linux   LDFLAGS: -l%[1]s -lEGL -ldl
+//#cgo windows CFLAGS: -DGL_PLATFORM_WINDOWS
+//#cgo windows LDFLAGS: -l%[1]s -lEGL
+import "C"
 `,
 	`
-int gl_init()
-{
-`,
-	`	return 0;
-}*/
+/*
+#include "glgo.h"
+
+#include <EGL/egl.h>
+
+#if defined(GL_PLATFORM_LINUX)
+#include <dlfcn.h>
+
+static void *_hGLES = NULL;
+
+static void* _glgo_GetProcAddress(const char* name) {
+	void *p = (void*)eglGetProcAddress(name);
+	if (p == NULL) {
+		if (_hGLES == NULL) {
+			_hGLES = dlopen("lib%[1]s.so%[2]s", RTLD_LAZY);
+		}
+		if (_hGLES != NULL) {
+			p = dlsym(_hGLES, name);
+		}
+	}
+	return p;
+}
+#elif defined(GL_PLATFORM_WINDOWS)
+#include <Windows.h>
+
+static HMODULE _hGLES = NULL;
+
+static void* _glgo_GetProcAddress(const char* name) {
+	void *p = (void*)eglGetProcAddress(name);
+	if (p == NULL) {
+		if (_hGLES == NULL) {
+			_hGLES = LoadLibrary(TEXT("lib%[1]s.dll"));
+		}
+		p = GetProcAddress(_hGLES, name);
+	}
+	return p;
+}
+#else
+#error "Unsupport platform"
+#endif
+*/
 import "C"
 `,
 	`
-const (
-`,
-	`)
+func c_boolean(b Boolean) C.GLboolean {
+	if b {
+		return 1
+	}
+	return 0
+}
 `,
 	`
-func Init() int {
-	return int(C.gl_init())
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Tracer is called before and after every command when it is installed by
+// SetTracer, args are the Go arguments of the command and ret is its result,
+// or nil if it has no result.
+type Tracer interface {
+	Before(command string, args []interface{})
+	After(command string, args []interface{}, ret interface{}, d time.Duration)
+}
+
+// tracer is loaded once by every traced call, so SetTracer can run while
+// other goroutines call commands
+var tracer atomic.Pointer[Tracer]
+
+// SetTracer installs t, commands don't trace if t is nil.
+func SetTracer(t Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&t)
 }
+
+type trace_call struct {
+	tracer  Tracer
+	command string
+	args    []interface{}
+	start   time.Time
+}
+
+func trace_begin(t Tracer, command string, args ...interface{}) trace_call {
+	t.Before(command, args)
+	return trace_call{
+		tracer:  t,
+		command: command,
+		args:    args,
+		start:   time.Now(),
+	}
+}
+
+func (c *trace_call) end(ret interface{}) {
+	d := time.Since(c.start)
+	c.tracer.After(c.command, c.args, ret, d)
+}
+`,
+	`// generate by genglgo[https://github.com/vizee/genglgo]
+// target: %s, updated at: %s
+// features: %s
+
 `,
 }
 
 type param_info struct {
-	name  string
-	ptype string
+	name   string
+	ptype  string
+	group  string
+	len_   string
+	gotype string
+	// gotype is an interface of enums, like AnyEnum
+	enum_iface bool
 }
 
 type type_info struct {
@@ -132,8 +286,12 @@ type type_info struct {
 }
 
 type command_info struct {
-	params  []param_info
-	rettype string
+	params    []param_info
+	rettype   string
+	retgroup  string
+	retgotype string
+	// dispatch helper of the command, "" for the helper of its C signature
+	dispatch string
 }
 
 var gl_prefix_list = [...]string{
@@ -141,44 +299,78 @@ var gl_prefix_list = [...]string{
 	"gl",
 }
 
+// underlying Go types of GL types, they must have the same size as the C types
 var go_rawtype_map = map[string]string{
-	"GLenum":     "uint",
-	"GLboolean":  "uint8",
+	"GLenum":     "uint32",
+	"GLboolean":  "bool",
 	"GLbitfield": "uint32",
 	"GLbyte":     "int8",
 	"GLshort":    "int16",
-	"GLint":      "int",
+	"GLint":      "int32",
 	"GLubyte":    "uint8",
 	"GLushort":   "uint16",
-	"GLuint":     "uint",
-	"GLsizei":    "int",
+	"GLuint":     "uint32",
+	"GLsizei":    "int32",
 	"GLfloat":    "float32",
 	"GLclampf":   "float32",
 	"GLdouble":   "float64",
 	"GLclampd":   "float64",
 	"GLchar":     "int8",
 	"GLhalf":     "uint16",
-	"GLintptr":   "uintptr",
-	"GLsizeiptr": "uintptr",
+	"GLintptr":   "int",
+	"GLsizeiptr": "int",
 	"GLint64":    "int64",
 	"GLuint64":   "uint64",
 	"GLsync":     "unsafe.Pointer",
 	"GLfixed":    "int32",
+
+	"GLclampx":         "int32",
+	"GLcharARB":        "int8",
+	"GLhalfARB":        "uint16",
+	"GLhalfNV":         "uint16",
+	"GLintptrARB":      "int",
+	"GLsizeiptrARB":    "int",
+	"GLint64EXT":       "int64",
+	"GLuint64EXT":      "uint64",
+	"GLhandleARB":      "uint32",
+	"GLeglImageOES":    "unsafe.Pointer",
+	"GLvdpauSurfaceNV": "int",
 }
 
-var kw_list = [...]string{
-	"type",
-	"map",
-	"func",
-	"range",
-	"cap",
-	"string",
+// library name and soname suffix of OpenGL ES APIs
+var gles_library_map = map[string][2]string{
+	"gles1": {"GLESv1_CM", ".1"},
+	"gles2": {"GLESv2", ".2"},
 }
 
-var (
-	cgotype_map map[string]string
-	gotype_map  map[string]string
-)
+type target struct {
+	api         string
+	profile     string
+	version     string
+	min_version string
+	extensions  []string
+	pkg         string
+	output      string
+
+	typed_enums     bool
+	raw_enum_groups []string
+	slices          bool
+	strings         bool
+	debug           bool
+	trace           bool
+	enum_strings    bool
+	backend         string
+	fake            bool
+	no_loader       bool
+	batch           []string
+	analyzer        bool
+	used_by         string
+	// directory of the runtime hooks package of nocgo targets
+	fakecgo string
+	// every command gets its own dispatch helper like before helpers were
+	// shared by signatures, benchmarks compare both
+	dispatch_per_command bool
+}
 
 func is_same_api(a string, b string) bool {
 	return a == b || (a == "" && b == "gl") || (a == "gl" && b == "")
@@ -199,6 +391,12 @@ func kill_gl(s string) string {
 	return s
 }
 
+func go_typename(t string) string {
+	b := []byte(strings.TrimPrefix(t, "GL"))
+	b[0] = byte(unicode.ToUpper(rune(b[0])))
+	return string(b)
+}
+
 func comment_text(t string) string {
 	return "//" + strings.Replace(t, "\n", "\n//", -1)
 }
@@ -207,12 +405,18 @@ func map_cgotype(t string) string {
 	r := ""
 	p := 0
 	void := false
+	struct_ := false
 	for _, s := range strings.Split(strings.Replace(t, "*", " * ", -1), " ") {
 		if s == "void" || s == "GLvoid" {
 			void = true
 		} else if s == "*" {
 			p++
-		} else if strings.HasPrefix(s, "GL") {
+		} else if s == "struct" {
+			struct_ = true
+		} else if struct_ && s != "" {
+			r = "C.struct_" + s
+			struct_ = false
+		} else if s != "" && s != "const" {
 			r = "C." + s
 		}
 	}
@@ -233,7 +437,17 @@ func map_gotype(t string) string {
 		} else if s == "*" {
 			p++
 		} else if strings.HasPrefix(s, "GL") {
-			r = go_rawtype_map[s]
+			if _, ok := go_rawtype_map[s]; ok {
+				r = go_typename(s)
+			} else {
+				// callbacks like GLDEBUGPROC
+				r = "unsafe.Pointer"
+			}
+		} else if s == "size_t" {
+			r = "uintptr"
+		} else if s != "" && s != "const" && s != "struct" {
+			// opaque types like struct _cl_context
+			void = true
 		}
 	}
 	if void && p > 0 {
@@ -245,56 +459,129 @@ func map_gotype(t string) string {
 
 func gen_c_def_type(types []type_info) string {
 	s := "\n"
-	s += "//#ifndef __gl_h_\n"
+	s += "#ifndef __gl_h_\n"
 	for _, t := range types {
-		s += comment_text(t.text) + "\n"
+		s += t.text + "\n"
 	}
-	s += "//#endif\n"
-	return s + "import \"C\"\n"
+	s += "#endif\n"
+	return s
 }
 
-func gen_c_def_command(command string, info command_info) string {
+func gen_go_type(types []type_info) string {
+	max_len := 0
+	for _, t := range types {
+		if _, ok := go_rawtype_map[t.name]; ok && len(go_typename(t.name)) > max_len {
+			max_len = len(go_typename(t.name))
+		}
+	}
+	s := "\ntype (\n"
+	for _, t := range types {
+		if rawtype, ok := go_rawtype_map[t.name]; ok {
+			name := go_typename(t.name)
+			s += "\t" + name + strings.Repeat(" ", max_len-len(name)) + " " + rawtype + "\n"
+		}
+	}
+	s += ")\n"
+	return s
+}
+
+// gen_go_assert_type makes the build fail if a Go type and its C type
+// have different sizes.
+func gen_go_assert_type(types []type_info) string {
+	s := "\n// static assertions that Go types have the same size as C types\nvar (\n"
+	for _, t := range types {
+		if _, ok := go_rawtype_map[t.name]; ok {
+			gosize := "unsafe.Sizeof(*new(" + go_typename(t.name) + "))"
+			csize := "unsafe.Sizeof(*new(C." + t.name + "))"
+			s += "\t_ [" + gosize + " - " + csize + "]byte\n"
+			s += "\t_ [" + csize + " - " + gosize + "]byte\n"
+		}
+	}
+	s += ")\n"
+	return s
+}
+
+// c_mangle_type turns C type ctype into an identifier, like cGLcharp for
+// const GLchar *.
+func c_mangle_type(ctype string) string {
+	s := ""
+	for _, w := range strings.Fields(strings.Replace(ctype, "*", " * ", -1)) {
+		switch w {
+		case "const":
+			s += "c"
+		case "*":
+			s += "p"
+		default:
+			s += w
+		}
+	}
+	return s
+}
+
+// c_dispatch_name names the dispatch helper shared by commands with the C
+// signature of info, like void_GLenum_GLint for void (GLenum, GLint), or
+// the command of info if it has its own helper.
+func c_dispatch_name(info command_info) string {
+	if info.dispatch != "" {
+		return info.dispatch
+	}
+	name := c_mangle_type(info.rettype)
+	for _, p := range info.params {
+		name += "_" + c_mangle_type(p.ptype)
+	}
+	return name
+}
+
+// gen_c_def_dispatch generates the dispatch helper of the C signature of
+// info, it calls the function pointer passed as the first argument.
+func gen_c_def_dispatch(info command_info) string {
 	var (
 		params    string
 		paramargs string
 	)
-	for _, p := range info.params {
+	for i, p := range info.params {
 		if params != "" {
 			params += ", "
 		}
 		if paramargs != "" {
 			paramargs += ", "
 		}
-		params += p.ptype + " " + p.name
-		paramargs += p.name
+		params += p.ptype + " p" + strconv.Itoa(i)
+		paramargs += "p" + strconv.Itoa(i)
+	}
+	def := "GLGO_DISPATCH_DECL(" + info.rettype + ", " + c_dispatch_name(info)
+	if params != "" {
+		def += ", " + params
 	}
-	def := "GLGO_COMMAND_DECL(" + info.rettype + ", " + command + ", " + params + ")\n"
+	def += ")\n"
 	if info.rettype == "void" {
-		def += "GLGO_COMMAND_0RET"
+		def += "GLGO_DISPATCH_0RET"
 	} else {
-		def += "GLGO_COMMAND_RET"
+		def += "GLGO_DISPATCH_RET"
 	}
-	def += "(" + command + ", " + paramargs + ")\n"
+	def += "(" + paramargs + ")\n"
 	return def
 }
 
-func gen_c_init_command(command string) string {
-	return `	if (GLGO_COMMAND_GETPROC(` + command + `) == NULL) {
-		return __LINE__;
+func param_gotype(p param_info) string {
+	if p.gotype != "" {
+		return p.gotype
 	}
-`
+	return map_gotype(p.ptype)
 }
 
-func save_go_kw(w string) string {
-	for _, k := range kw_list {
-		if k == w {
-			return w + "_"
-		}
+func ret_gotype(info command_info) string {
+	if info.retgotype != "" {
+		return info.retgotype
 	}
-	return w
+	return map_gotype(info.rettype)
 }
 
-func gen_go_func_command(command string, info command_info) string {
+// gen_go_func_command generates the Context method wrapping command, debug
+// wrappers check errors after calling command and traced wrappers call the
+// installed tracer around command. nocgo wrappers call command by its
+// trampoline.
+func gen_go_func_command(command string, goname string, info command_info, doc string, debug bool, trace bool, nocgo bool) string {
 	params := ""
 	paramargs := ""
 	for i, p := range info.params {
@@ -303,10 +590,10 @@ func gen_go_func_command(command string, info command_info) string {
 			params += ", "
 		}
 		params += name
-		gotype := gotype_map[p.ptype]
+		gotype := param_gotype(p)
 		nexttype := ""
 		if i < len(info.params)-1 {
-			nexttype = gotype_map[info.params[i+1].ptype]
+			nexttype = param_gotype(info.params[i+1])
 		}
 		if gotype != nexttype {
 			params += " " + gotype
@@ -314,50 +601,223 @@ func gen_go_func_command(command string, info command_info) string {
 		if paramargs != "" {
 			paramargs += ", "
 		}
-		cgotype := cgotype_map[p.ptype]
+		if p.enum_iface {
+			// enum interfaces pass their values by method enum
+			name, gotype = name+".enum()", "Enum"
+		}
+		if nocgo {
+			paramargs += gen_go_nocgo_arg(name, gotype)
+			continue
+		}
+		cgotype := map_cgotype(p.ptype)
+		if gotype == "Boolean" {
+			paramargs += "c_boolean(" + name + ")"
+			continue
+		}
 		if strings.HasPrefix(cgotype, "*") {
 			cgotype = "(" + cgotype + ")"
 			name = "unsafe.Pointer(" + name + ")"
 		}
 		paramargs += cgotype + "(" + name + ")"
 	}
-	s := "\n"
-	s += "func " + kill_gl(command) + "(" + params + ") "
+	check := ""
+	if debug {
+		check = gen_go_debug_call(command, info)
+	}
+	call := "C.glgo_" + c_dispatch_name(info) + "(ctx.p_" + command
+	if nocgo {
+		call = "call_" + nocgo_signature(info) + "(ctx.p_" + command
+	}
+	if paramargs != "" {
+		call += ", " + paramargs
+	}
+	call += ")"
+	rettype := ""
 	if info.rettype != "void" {
-		rettype := gotype_map[info.rettype]
-		s += rettype + " {\n"
-		if strings.HasPrefix(rettype, "*") {
-			rettype = "(" + rettype + ")"
+		rettype = ret_gotype(info)
+		if nocgo {
+			call = gen_go_nocgo_ret(call, rettype)
+		} else if rettype == "Boolean" {
+			call = "Boolean(" + call + " != 0)"
+		} else if strings.HasPrefix(rettype, "*") {
+			call = "(" + rettype + ")(" + call + ")"
+		} else {
+			call = rettype + "(" + call + ")"
 		}
-		s += "\treturn " + rettype + "(C." + command + "(" + paramargs + "))\n"
-	} else {
-		s += "{\n"
-		s += "\tC." + command + "(" + paramargs + ")\n"
 	}
+	// body calls command, then checks errors and returns the result
+	body := func(indent string, traced bool) string {
+		s := ""
+		if traced {
+			s += indent + "call_ := trace_begin(*tracer_, \"" + command + "\""
+			for _, p := range info.params {
+				s += ", " + save_go_kw(p.name)
+			}
+			s += ")\n"
+		}
+		if rettype == "" {
+			s += indent + call + "\n"
+			if traced {
+				s += indent + "call_.end(nil)\n"
+			}
+			s += strings.Replace(check, "\t", indent, -1)
+			return s
+		}
+		if !traced && check == "" {
+			return s + indent + "return " + call + "\n"
+		}
+		s += indent + "ret_ := " + call + "\n"
+		if traced {
+			s += indent + "call_.end(ret_)\n"
+		}
+		s += strings.Replace(check, "\t", indent, -1)
+		return s + indent + "return ret_\n"
+	}
+	s := "\n" + doc
+	s += "func (ctx *Context) " + goname + "(" + params + ") "
+	if rettype != "" {
+		s += rettype + " "
+	}
+	s += "{\n"
+	if trace {
+		s += "\tif tracer_ := tracer.Load(); tracer_ != nil {\n"
+		s += body("\t\t", true)
+		if rettype == "" {
+			s += "\t\treturn\n"
+		}
+		s += "\t}\n"
+	}
+	s += body("\t", false)
 	s += "}\n"
 	return s
 }
 
-func generate(glxml string, api string, profile string, number string, glgo string) error {
-	registry, err := load_glxml(glxml)
+// write_file writes parts to path, Go sources are formatted by gofmt.
+func write_file(path string, parts ...string) error {
+	data := []byte(strings.Join(parts, ""))
+	if strings.HasSuffix(path, ".go") {
+		src, err := format.Source(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		data = src
+	}
+	return os.WriteFile(path, data, 0664)
+}
+
+// generated_marker is in the header of every file written by genglgo.
+const generated_marker = "generate by genglgo["
+
+// remove_generated removes files written by genglgo from dir, other files
+// are kept. A missing dir has nothing to remove.
+func remove_generated(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	max_ver, err := strconv.ParseFloat(number, 64)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !(strings.HasSuffix(name, ".go") || strings.HasSuffix(name, ".s") || strings.HasSuffix(name, ".h")) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		head := make([]byte, 1024)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		if strings.Contains(string(head[:n]), generated_marker) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// is_api_member reports whether an element with api attribute attr
+// belongs to api, elements without api attribute belong to all APIs.
+func is_api_member(api string, attr string) bool {
+	return attr == "" || is_same_api(api, attr)
+}
+
+func default_profile(api string) string {
+	switch api {
+	case "gl", "":
+		return "core"
+	case "gles1":
+		return "common"
+	}
+	return ""
+}
+
+func is_supported_api(supported string, api string, profile string) bool {
+	for _, s := range strings.Split(supported, "|") {
+		if is_same_api(api, s) || (s == "glcore" && is_same_api(api, "gl") && profile == "core") {
+			return true
+		}
+	}
+	return false
+}
+
+func generate(registry *glxml_registry, t *target) error {
+	api, profile := t.api, t.profile
+	min_ver, max_ver, err := select_versions(registry, t)
 	if err != nil {
 		return err
 	}
+	number, min_number := max_ver.String(), ""
+	if min_ver != max_ver {
+		min_number = min_ver.String()
+	}
+	platforms := []string{"windows", "linux"}
+	nocgo := false
+	switch t.backend {
+	case "", "cgo":
+	case "nocgo":
+		platforms = nocgo_platforms
+		nocgo = true
+	default:
+		return errors.New("unknown backend: " + t.backend)
+	}
 	var (
 		is_types    = make(map[string]bool)
 		is_enums    = make(map[string]bool)
 		is_commands = make(map[string]bool)
+		// versions adding enums and commands, and those added after min
+		// version
+		added = make(map[string]string)
+		since = make(map[string]string)
+		// extensions providing commands
+		provided = make(map[string][]string)
+		features []string
+		// numbers and versions of features
+		feature_numbers  []string
+		feature_versions []gl_version
 	)
 	for _, feature := range registry.feature {
-		ver, err := strconv.ParseFloat(feature.number, 64)
+		ver, err := parse_version(feature.number)
 		if err != nil {
 			return err
 		}
-		if is_same_api(api, feature.api) && ver <= max_ver {
+		if is_same_api(api, feature.api) && !max_ver.less(ver) {
+			features = append(features, feature.name)
+			feature_numbers = append(feature_numbers, feature.number)
+			feature_versions = append(feature_versions, ver)
+			add := func(set map[string]bool, name string) {
+				if !set[name] {
+					added[name] = feature.number
+					if min_ver.less(ver) {
+						since[name] = feature.number
+					}
+				}
+				set[name] = true
+			}
 			for _, require := range feature.require {
 				if require.profile != "" && require.profile != profile {
 					continue
@@ -366,10 +826,10 @@ func generate(glxml string, api string, profile string, number string, glgo stri
 					is_types[type_.name] = true
 				}
 				for _, enum := range require.enum {
-					is_enums[enum.name] = true
+					add(is_enums, enum.name)
 				}
 				for _, command := range require.command {
-					is_commands[command.name] = true
+					add(is_commands, command.name)
 				}
 			}
 			for _, remove := range feature.remove {
@@ -378,25 +838,113 @@ func generate(glxml string, api string, profile string, number string, glgo stri
 				}
 				for _, enum := range remove.enum {
 					delete(is_enums, enum.name)
+					delete(added, enum.name)
+					delete(since, enum.name)
 				}
 				for _, command := range remove.command {
 					delete(is_commands, command.name)
+					delete(added, command.name)
+					delete(since, command.name)
+				}
+			}
+		}
+	}
+	for _, name := range t.extensions {
+		found := false
+		for _, extension := range registry.extensions.extension {
+			if extension.name != name {
+				continue
+			}
+			if !is_supported_api(extension.supported, api, profile) {
+				return fmt.Errorf("extension %s is not supported by %s-%s", name, api, profile)
+			}
+			for _, require := range extension.require {
+				if require.api != "" && !is_same_api(api, require.api) {
+					continue
+				}
+				if require.profile != "" && require.profile != profile {
+					continue
+				}
+				for _, type_ := range require.type_ {
+					is_types[type_.name] = true
 				}
+				for _, enum := range require.enum {
+					is_enums[enum.name] = true
+					delete(since, enum.name)
+				}
+				for _, command := range require.command {
+					is_commands[command.name] = true
+					delete(since, command.name)
+					provided[command.name] = append(provided[command.name], name)
+				}
+			}
+			found = true
+			break
+		}
+		if !found {
+			return errors.New("unknown extension: " + name)
+		}
+	}
+	if t.used_by != "" {
+		used, err := scan_go_usage(t.used_by, t.output)
+		if err != nil {
+			return err
+		}
+		// the loader detects the version of the context by glGetString
+		keep := []string{"glGetString", "GL_VERSION"}
+		if t.debug {
+			keep = append(keep, "glGetError", "glBegin", "glEnd")
+			keep = append(keep, gl_error_list[:]...)
+		}
+		shake_unused(used, is_commands, is_enums, keep)
+		for name := range since {
+			if !is_commands[name] && !is_enums[name] {
+				delete(since, name)
 			}
 		}
 	}
 	var (
-		enums_map    = make(map[string]string, len(is_enums))
-		commands_map = make(map[string]command_info, len(is_commands))
+		enums_list    = make([]string, 0, len(is_enums))
+		enums_map     = make(map[string]string, len(is_enums))
+		commands_list = make([]string, 0, len(is_commands))
+		commands_map  = make(map[string]command_info, len(is_commands))
 	)
-	cgotype_map = make(map[string]string)
-	gotype_map = make(map[string]string)
+	enums_ull := make(map[string]bool)
+	// enums which are neither bits nor special numbers like TRUE and
+	// INVALID_INDEX, they are typed Enum with typed enums. enums of headers
+	// may be bits too, so they aren't plain
+	enums_plain := make(map[string]bool)
+	enums_removed := make(map[string]string)
+	enums_level := make(map[string]int)
+	// names of enums by Go names, several names of one Go name collide
+	enums_origin := make(map[string][]string)
+	base_enums := make(map[string]bool)
+	numbers, levels := version_levels(since)
+	removals := index_core_removals(registry, api)
 	max_enums_len := 0
 	for _, enums := range registry.enums {
 		for _, e := range enums.enum {
-			if is_enums[e.name] {
+			if is_enums[e.name] && is_api_member(api, e.api) {
 				name := kill_gl(e.name)
+				if _, ok := enums_map[name]; !ok {
+					enums_list = append(enums_list, name)
+				}
 				enums_map[name] = e.value
+				if e.type_ == "ull" {
+					enums_ull[name] = true
+				}
+				if e.type_ == "" && enums.type_ != "bitmask" && enums.group != "SpecialNumbers" && enums.comment != header_enums_comment {
+					enums_plain[name] = true
+				}
+				if feature, ok := removals[e.name]; ok {
+					enums_removed[name] = feature
+				}
+				add_go_name(enums_origin, name, e.name)
+				if number, ok := since[e.name]; ok {
+					enums_level[name] = levels[number]
+				} else {
+					base_enums[e.name] = true
+				}
 				if len(name) > max_enums_len {
 					max_enums_len = len(name)
 				}
@@ -415,75 +963,502 @@ func generate(glxml string, api string, profile string, number string, glgo stri
 				if p.ptype != "" {
 					is_types[p.ptype] = true
 				}
-				ptype := strings.TrimSpace(text[:len(text)-len(p.name)])
+				ptype := strings.TrimSpace(text[:strings.LastIndex(text, p.name)])
+				if strings.HasSuffix(text, "]") {
+					// array params like GLuint baseAndCount[2]
+					ptype += " *"
+				}
 				param_list[i] = param_info{
 					name:  p.name,
 					ptype: ptype,
+					group: p.group,
+					len_:  p.len_,
 				}
-				gotype_map[ptype] = map_gotype(ptype)
-				cgotype_map[ptype] = map_cgotype(ptype)
 			}
 			rettype := strings.TrimSpace(text[:len(text)-len(c.proto.name)])
 			info := command_info{
-				rettype: rettype,
-				params:  param_list,
+				rettype:  rettype,
+				retgroup: c.proto.group,
+				params:   param_list,
+			}
+			if t.dispatch_per_command {
+				info.dispatch = c.proto.name
 			}
-			gotype_map[rettype] = map_gotype(rettype)
-			cgotype_map[rettype] = map_cgotype(rettype)
+			commands_list = append(commands_list, c.proto.name)
 			commands_map[c.proto.name] = info
 		}
 	}
-	for _, t := range registry.types.type_ {
-		if is_types[t.name] && is_same_api(api, t.api) {
+	var batch []string
+	if len(t.batch) != 0 {
+		batch, err = batch_commands(commands_list, commands_map, t.batch)
+		if err != nil {
+			return err
+		}
+	}
+	// types with a matching api attribute override the generic ones
+	api_types := make(map[string]int)
+	for i, t := range registry.types.type_ {
+		if t.api != "" && is_same_api(api, t.api) {
+			api_types[t.name] = i
+		}
+	}
+	is_api_type := func(i int) bool {
+		t := &registry.types.type_[i]
+		if !is_api_member(api, t.api) {
+			return false
+		}
+		j, ok := api_types[t.name]
+		return !ok || i == j
+	}
+	for i, t := range registry.types.type_ {
+		if is_types[t.name] && is_api_type(i) {
 			if t.requires != "" {
 				is_types[t.requires] = true
 			}
 		}
 	}
 	ctypes_list := make([]type_info, 0, len(is_types))
-	for _, t := range registry.types.type_ {
-		if is_types[t.name] && is_same_api(api, t.api) {
+	for i, t := range registry.types.type_ {
+		if is_types[t.name] && is_api_type(i) {
 			ctypes_list = append(ctypes_list, type_info{
 				name: t.name,
 				text: t.text,
 			})
 		}
 	}
-	err = os.MkdirAll(filepath.Dir(glgo), 0775)
+	outdir := t.output
+	err = os.MkdirAll(outdir, 0775)
 	if err != nil {
 		return err
 	}
-	f, err := os.Create(glgo)
+	// files of earlier runs with other options would break the package
+	if err := remove_generated(outdir); err != nil {
+		return err
+	}
+	if err := remove_generated(filepath.Join(outdir, "glversion")); err != nil {
+		return err
+	}
+	// removing fails if glversion holds other files, which are kept
+	os.Remove(filepath.Join(outdir, "glversion"))
+	// API_VERSION is the version built without version tags
+	base_number := number
+	if min_number != "" {
+		base_number = min_number
+		number = min_number + "-" + number
+	}
+	target := api + "-" + number
+	if profile != "" {
+		target = api + "-" + profile + "-" + number
+	}
+	if len(t.extensions) != 0 {
+		target += "+" + strings.Join(t.extensions, "+")
+	}
+	updated := time.Now().Format("2006-01-02 15:04:05")
+	// features and extensions of the package are echoed into headers
+	selected := strings.Join(append(features, t.extensions...), ", ")
+	go_header := func(tags string) string {
+		return fmt.Sprintf(templates[0], tags, t.pkg, target, updated, selected)
+	}
+	header := go_header(build_tags(platforms, ""))
+	var loader string
+	// with glGetString the loader detects the version of the context and
+	// requires only commands of the versions it supports
+	var detect *context_features
+	if is_commands["glGetString"] && is_enums["GL_VERSION"] {
+		detect = &context_features{
+			versions: feature_versions,
+			commands: make(map[string]string),
+		}
+		fields := make(map[string]string)
+		for i, name := range features {
+			field := kill_gl(name)
+			detect.fields = append(detect.fields, field)
+			fields[feature_numbers[i]] = field
+		}
+		for _, command := range commands_list {
+			if number := added[command]; number != "" {
+				detect.commands[command] = fields[number]
+			}
+		}
+		info := commands_map["glGetString"]
+		version := kill_gl("GL_VERSION")
+		if nocgo {
+			detect.version = "go_string(call_" + nocgo_signature(info) + "(ctx.p_glGetString, uintptr(" + version + ")))"
+		} else {
+			detect.version = "C.GoString((*C.char)(unsafe.Pointer(C.glgo_" + c_dispatch_name(info) + "(ctx.p_glGetString, C.GLenum(" + version + ")))))"
+		}
+	}
+	if nocgo {
+		if !t.no_loader {
+			loader = gen_go_nocgo_loader(api)
+		}
+		loader += gen_go_context(commands_list, since, "uintptr", t.debug && is_commands["glBegin"], detect, nil)
+	} else {
+		glgo_h := fmt.Sprintf(templates[9], target, updated, selected)
+		glgo_h += gen_c_def_type(ctypes_list)
+		glgo_h += templates[12]
+		// commands with the same C signature share one dispatch helper
+		dispatches := make(map[string]bool)
+		for _, command := range commands_list {
+			info := commands_map[command]
+			if name := c_dispatch_name(info); !dispatches[name] {
+				dispatches[name] = true
+				glgo_h += gen_c_def_dispatch(info)
+			}
+		}
+		glgo_h += templates[10]
+		if err := write_file(filepath.Join(outdir, "glgo.h"), glgo_h); err != nil {
+			return err
+		}
+
+		// without loader nothing links the GL library, templates[4] includes
+		// glgo.h
+		if t.no_loader {
+			loader = templates[4]
+		} else if lib, ok := gles_library_map[api]; ok {
+			loader = fmt.Sprintf(templates[13], lib[0]) + fmt.Sprintf(templates[14], lib[0], lib[1])
+			loader += templates[4] + templates[5] + templates[6]
+		} else {
+			loader = templates[2] + templates[3]
+			loader += templates[4] + templates[5] + templates[6]
+		}
+		loader += gen_go_context(commands_list, since, "*[0]byte", t.debug && is_commands["glBegin"], detect, batch)
+	}
+	loader += "\nconst (\n"
+	loader += fmt.Sprintf("\tAPI_NAME    = \"%s\"\n\tAPI_VERSION = \"%s\"\n", api, base_number)
+	loader += ")\n"
+	if !nocgo {
+		loader += gen_go_assert_type(ctypes_list)
+	}
+	if err := write_file(filepath.Join(outdir, "gl.go"), header, templates[1], context_templates[0], loader); err != nil {
+		return err
+	}
+
+	groups := resolve_enum_groups(registry, t, commands_list, commands_map, is_enums)
+	enums_type, enum_sets := group_enum_types(groups)
+	if groups != nil {
+		// enums without group are passed to AnyEnum params
+		for name := range enums_plain {
+			if _, ok := enums_type[name]; !ok {
+				enums_type[name] = "Enum"
+			}
+		}
+	}
+	types := gen_go_type(ctypes_list) + gen_go_group_type(groups, enum_sets)
+	if strings.Contains(types, "unsafe.") {
+		types = templates[1] + types
+	}
+	if err := write_file(filepath.Join(outdir, "types.go"), header, types); err != nil {
+		return err
+	}
+
+	// enums, commands, helpers and debug wrappers of every level, level 0
+	// is built without version tags
+	enums := make([]string, len(numbers)+1)
+	for _, name := range enums_list {
+		l := enums_level[name]
+		if feature, ok := enums_removed[name]; ok {
+			enums[l] += "\t" + gen_go_deprecated(feature)
+		}
+		if gotype, ok := enums_type[name]; ok && !enums_ull[name] {
+			enums[l] += "\t" + name + " " + gotype + " = " + enums_map[name] + "\n"
+			continue
+		}
+		enums[l] += "\t" + name + strings.Repeat(" ", max_enums_len-len(name)) + " = " + enums_map[name] + "\n"
+	}
+	for l := range enums {
+		enums[l] = templates[7] + enums[l] + templates[8]
+	}
+	if err := write_file(filepath.Join(outdir, "enums.go"), header, enums[0]); err != nil {
+		return err
+	}
+
+	var (
+		commands        = make([]string, len(numbers)+1)
+		helpers         = make([]string, len(numbers)+1)
+		debug           = make([]string, len(numbers)+1)
+		procs           = make([][]string, len(numbers)+1)
+		signatures      = make(map[string]bool)
+		gonames         = make(map[string]string)
+		commands_origin = make(map[string][]string)
+		docs            = index_command_docs(registry, api, profile, removals)
+		uses_cboolean   = false
+	)
+	for _, command := range commands_list {
+		l := levels[since[command]]
+		procs[l] = append(procs[l], command)
+		info := commands_map[command]
+		goname := kill_gl(command)
+		helper := ""
+		if t.strings {
+			helper = gen_go_string_command("(ctx *Context) "+goname, "ctx."+goname+"Ptr", info, nocgo)
+		}
+		if t.slices && helper == "" {
+			helper = gen_go_slice_command("(ctx *Context) "+goname, "ctx."+goname+"Ptr", info)
+		}
+		gonames[goname] = command
+		add_go_name(commands_origin, goname, command)
+		wrapper := ""
+		if helper != "" {
+			// the helper gets the doc of the command, the Ptr wrapper
+			// refers to it
+			helper = "\n" + gen_go_command_doc(goname, "", command, info, docs[command]) + helper[1:]
+			wrapper = goname
+			goname += "Ptr"
+			gonames[goname] = command
+			add_go_name(commands_origin, goname, command)
+		}
+		doc := gen_go_command_doc(goname, wrapper, command, info, docs[command])
+		commands[l] += gen_go_func_command(command, goname, info, doc, false, t.trace, nocgo)
+		if t.debug {
+			debug[l] += gen_go_func_command(command, goname, info, doc, true, t.trace, nocgo)
+		}
+		helpers[l] += helper
+		signatures[nocgo_signature(info)] = true
+		uses_cboolean = uses_cboolean || strings.Contains(commands[l], "c_boolean(")
+	}
+	types_origin := make(map[string][]string)
+	for _, t := range ctypes_list {
+		if _, ok := go_rawtype_map[t.name]; ok {
+			add_go_name(types_origin, go_typename(t.name), t.name)
+		}
+	}
+	for name, g := range groups {
+		add_go_name(types_origin, g.name, "group "+name)
+	}
+	if err := check_go_names(map[string]map[string][]string{
+		"enum":    enums_origin,
+		"command": commands_origin,
+		"type":    types_origin,
+	}); err != nil {
+		return err
+	}
+	if uses_cboolean {
+		if nocgo {
+			helpers[0] += nocgo_templates[8]
+		} else {
+			helpers[0] += templates[15]
+		}
+	}
+	if helpers[0] != "" {
+		if err := write_go_file(outdir, "helpers.go", header, helpers[0], nocgo); err != nil {
+			return err
+		}
+	}
+	if nocgo {
+		replays := make(map[string]bool)
+		for _, command := range batch {
+			replays[nocgo_signature(commands_map[command])] = true
+		}
+		calls, amd64, arm64 := gen_nocgo_calls(signatures, replays)
+		if err := write_go_file(outdir, "call.go", header, calls, nocgo); err != nil {
+			return err
+		}
+		asm_header := fmt.Sprintf(templates[17], target, updated, selected)
+		if err := write_file(filepath.Join(outdir, "call_linux_amd64.s"), asm_header, amd64); err != nil {
+			return err
+		}
+		if err := write_file(filepath.Join(outdir, "call_linux_arm64.s"), asm_header, arm64); err != nil {
+			return err
+		}
+		// the runtime hooks are shared by all nocgo packages of a binary
+		path, err := import_path(t.fakecgo)
+		if err != nil {
+			return err
+		}
+		if err := write_file(filepath.Join(outdir, "fakecgo.go"), header, "\nimport (\n\t_ \""+path+"\"\n)\n"); err != nil {
+			return err
+		}
+	}
+	if t.enum_strings {
+		if err := write_file(filepath.Join(outdir, "enumstring.go"), header, gen_go_enum_string(registry, api, is_enums)); err != nil {
+			return err
+		}
+	}
+	if t.trace {
+		if err := write_file(filepath.Join(outdir, "trace.go"), header, templates[16]); err != nil {
+			return err
+		}
+	}
+	// recording methods of batched commands of later versions are built with
+	// their tags
+	batched := make([]string, len(numbers)+1)
+	if len(batch) != 0 {
+		buffer, methods := gen_go_batch(batch, commands_map, nocgo)
+		for _, command := range batch {
+			batched[levels[since[command]]] += methods[command]
+		}
+		if err := write_go_file(outdir, "batch.go", header, buffer+batched[0], nocgo); err != nil {
+			return err
+		}
+	}
+	funcs, err := parse_go_funcs(commands[0]+helpers[0], "*Context")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	f.WriteString(templates[0])
-	f.WriteString(fmt.Sprintf("// target: %s-%s-%s, updated at: %s", api, profile, number, time.Now().Format("2006-01-02 15:04:05")))
-	f.WriteString(templates[1])
-	f.WriteString(templates[2])
-	f.WriteString(gen_c_def_type(ctypes_list))
-	f.WriteString(templates[3])
-	f.WriteString(templates[4])
-	for k, v := range commands_map {
-		f.WriteString(gen_c_def_command(k, v))
-	}
-	f.WriteString(templates[5])
-	for k, _ := range commands_map {
-		f.WriteString(gen_c_init_command(k))
-	}
-	f.WriteString(templates[6])
-	f.WriteString("\nconst (\n")
-	f.WriteString(fmt.Sprintf("\tAPI_NAME    = \"%s\"\n\tAPI_VERSION = \"%s\"\n", api, number))
-	f.WriteString(")\n")
-	f.WriteString(templates[7])
-	for k, v := range enums_map {
-		f.WriteString("\t" + k + strings.Repeat(" ", max_enums_len-len(k)) + " = " + v + "\n")
-	}
-	f.WriteString(templates[8])
-	for command, info := range commands_map {
-		f.WriteString(gen_go_func_command(command, info))
-	}
-	f.WriteString(templates[9])
+	functions := gen_go_default(funcs)
+	if strings.Contains(functions, "unsafe.") {
+		functions = templates[1] + functions
+	}
+	if err := write_file(filepath.Join(outdir, "functions.go"), header, functions); err != nil {
+		return err
+	}
+	// with later versions, GL of every highest version is declared by a
+	// file built only for that version
+	write_iface := func(name string, tags string, funcs []go_func_decl) error {
+		iface := gen_go_iface_decl(funcs)
+		if strings.Contains(iface, "unsafe.") {
+			iface = templates[1] + iface
+		}
+		return write_file(filepath.Join(outdir, name), go_header(build_tags(platforms, "")+tags), iface)
+	}
+	iface_funcs := funcs
+	if t.fake {
+		iface := gen_go_iface(funcs)
+		if len(numbers) != 0 {
+			iface = gen_go_default_impl(funcs)
+			if err := write_iface("iface_"+version_tag(api, base_number)+".go", version_exclusive_constraint(api, numbers, 0), iface_funcs); err != nil {
+				return err
+			}
+		}
+		if strings.Contains(iface, "unsafe.") {
+			iface = templates[1] + iface
+		}
+		if err := write_file(filepath.Join(outdir, "iface.go"), header, iface); err != nil {
+			return err
+		}
+		fake := gen_go_fake(funcs)
+		if strings.Contains(fake, "unsafe.") {
+			fake = templates[1] + fake
+		}
+		if err := write_file(filepath.Join(outdir, "fake.go"), header, fake); err != nil {
+			return err
+		}
+	}
+	if t.analyzer {
+		dir := filepath.Join(outdir, "glversion")
+		if err := os.MkdirAll(dir, 0775); err != nil {
+			return err
+		}
+		analyzer := gen_go_analyzer(api, profile, t.pkg, target, updated, gonames, added, provided)
+		if err := write_file(filepath.Join(dir, "glversion.go"), analyzer); err != nil {
+			return err
+		}
+	}
+	// additions of versions after min version are built with their tags
+	for l := 1; l < len(commands); l++ {
+		constraint := version_constraint(api, numbers, l-1)
+		header_version := go_header(build_tags(platforms, "") + constraint)
+		funcs, err := parse_go_funcs(commands[l]+helpers[l], "*Context")
+		if err != nil {
+			return err
+		}
+		body := enums[l] + gen_go_version_procs(procs[l], detect) + helpers[l] + gen_go_default(funcs) + batched[l]
+		if t.fake {
+			body += gen_go_forward("(Default) ", "", funcs) + gen_go_fake_methods(funcs)
+			iface_funcs = append(iface_funcs, funcs...)
+			sort.Slice(iface_funcs, func(i, j int) bool {
+				return iface_funcs[i].name < iface_funcs[j].name
+			})
+			if err := write_iface("iface_"+version_tag(api, numbers[l-1])+".go", version_exclusive_constraint(api, numbers, l), iface_funcs); err != nil {
+				return err
+			}
+		}
+		if err := write_go_file(outdir, version_tag(api, numbers[l-1])+".go", header_version, body, nocgo); err != nil {
+			return err
+		}
+	}
+	commands_tag := ""
+	if t.debug {
+		if !is_commands["glGetError"] || since["glGetError"] != "" {
+			return errors.New("debug wrappers require glGetError")
+		}
+		header_debug := go_header(build_tags(platforms, "gldebug"))
+		debug[0] = gen_go_debug_check(is_commands["glBegin"], nocgo) + debug[0]
+		if err := write_go_file(outdir, "commands_debug.go", header_debug, debug[0], nocgo); err != nil {
+			return err
+		}
+		for l := 1; l < len(debug); l++ {
+			header_version := go_header(build_tags(platforms, "gldebug") + version_constraint(api, numbers, l-1))
+			if err := write_go_file(outdir, "commands_debug_"+version_tag(api, numbers[l-1])+".go", header_version, debug[l], nocgo); err != nil {
+				return err
+			}
+		}
+		// error enums of later versions may be missing
+		if err := write_file(filepath.Join(outdir, "debug.go"), header, gen_go_debug_error(base_enums)); err != nil {
+			return err
+		}
+		commands_tag = "!gldebug"
+		header = go_header(build_tags(platforms, commands_tag))
+	}
+	for l := 1; l < len(commands); l++ {
+		header_version := go_header(build_tags(platforms, commands_tag) + version_constraint(api, numbers, l-1))
+		if err := write_go_file(outdir, "commands_"+version_tag(api, numbers[l-1])+".go", header_version, commands[l], nocgo); err != nil {
+			return err
+		}
+	}
+	return write_go_file(outdir, "commands.go", header, commands[0], nocgo)
+}
+
+// write_go_file writes wrappers calling C to outdir/name, nocgo wrappers
+// don't import C.
+func write_go_file(outdir string, name string, header string, body string, nocgo bool) error {
+	if !nocgo {
+		body = templates[11] + body
+	}
+	if strings.Contains(body, "math.") {
+		body = "\nimport (\n\t\"math\"\n)\n" + body
+	}
+	if strings.Contains(body, "unsafe.") {
+		body = templates[1] + body
+	}
+	return write_file(filepath.Join(outdir, name), header, body)
+}
+
+// build_tags adds tag to every platform of build constraint platforms.
+func build_tags(platforms []string, tag string) string {
+	if tag == "" {
+		return strings.Join(platforms, " ")
+	}
+	return strings.Join(platforms, ","+tag+" ") + "," + tag
+}
+
+func generate_targets(registry *glxml_registry, targets []*target) error {
+	// targets can share the runtime hooks package, it is written once
+	written := make(map[string]bool)
+	for _, t := range targets {
+		if t.backend != "nocgo" {
+			continue
+		}
+		if t.fakecgo == "" {
+			t.fakecgo = filepath.Join(filepath.Dir(t.output), "fakecgo")
+		}
+		for _, other := range targets {
+			if other.output == t.fakecgo {
+				return fmt.Errorf("%s: fakecgo directory %s is the output of a target", t.output, t.fakecgo)
+			}
+		}
+		if !written[t.fakecgo] {
+			written[t.fakecgo] = true
+			if err := gen_nocgo_runtime(t.fakecgo); err != nil {
+				return fmt.Errorf("%s: %v", t.fakecgo, err)
+			}
+		}
+	}
+	errs := make([]error, len(targets))
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *target) {
+			errs[i] = generate(registry, t)
+			wg.Done()
+		}(i, t)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s: %v", targets[i].output, err)
+		}
+	}
 	return nil
 }
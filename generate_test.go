@@ -0,0 +1,267 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	test_registry      *glxml_registry
+	test_registry_err  error
+	test_registry_once sync.Once
+)
+
+func load_test_registry(t testing.TB) *glxml_registry {
+	t.Helper()
+	test_registry_once.Do(func() {
+		test_registry, test_registry_err = load_registry("res/gl.xml")
+	})
+	if test_registry_err != nil {
+		t.Fatal(test_registry_err)
+	}
+	return test_registry
+}
+
+// gen_test_module generates targets into a module in a temporary directory
+// with files, outputs of targets and names of files are relative to the
+// module root, and returns the root.
+func gen_test_module(t testing.TB, targets []*target, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	root := t.TempDir()
+	files["go.mod"] = "module gltest\n\ngo 1.21\n"
+	for name, src := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tg := range targets {
+		tg.output = filepath.Join(root, tg.output)
+		if tg.pkg == "" {
+			tg.pkg = filepath.Base(tg.output)
+		}
+	}
+	if err := generate_targets(load_test_registry(t), targets); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// go_command runs go with args in dir and the environment with env.
+func go_command(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off")
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestTypedEnums(t *testing.T) {
+	root := gen_test_module(t, []*target{{
+		api:         "gl",
+		profile:     "core",
+		version:     "4.5",
+		output:      "gl",
+		backend:     "nocgo",
+		typed_enums: true,
+	}}, map[string]string{
+		"ok/ok.go": `package ok
+
+import "gltest/gl"
+
+func Calls() {
+	// FLOAT is typed by its group, AnyEnum params without group take it
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 0, nil)
+	gl.VertexAttribPointer(0, 3, gl.HALF_FLOAT, false, 0, nil)
+	// TEXTURE_2D belongs to several groups
+	gl.Enable(gl.TEXTURE_2D)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+	gl.DrawArrays(gl.Enum(4), 0, 3)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	if gl.GetError() != gl.NO_ERROR {
+		panic("error")
+	}
+}
+`,
+		"header/header.go": `package header
+
+import "gltest/glh"
+
+func Calls() {
+	// enums of headers are untyped, so bits are passed to Bitfield params
+	// and values are converted to Enum
+	glh.Clear(glh.COLOR_BUFFER_BIT | glh.VENDOR_THING_BIT)
+	glh.Enable(glh.Enum(glh.VENDOR_THING))
+}
+`,
+		"bad/bad.go": `package bad
+
+import "gltest/gl"
+
+func Calls() {
+	gl.DrawArrays(gl.TEXTURE_2D, 0, 3)
+}
+`,
+	})
+	header := filepath.Join(t.TempDir(), "vendor.h")
+	err := os.WriteFile(header, []byte(`#ifndef GL_VENDOR_thing
+#define GL_VENDOR_thing 1
+#define GL_VENDOR_THING_BIT 0x00010000
+#define GL_VENDOR_THING 0x9990
+#endif
+`), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := load_registry("res/gl.xml," + header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = generate_targets(registry, []*target{{
+		api:         "gl",
+		profile:     "core",
+		version:     "4.5",
+		extensions:  []string{"GL_VENDOR_thing"},
+		pkg:         "glh",
+		output:      filepath.Join(root, "glh"),
+		backend:     "nocgo",
+		typed_enums: true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := []string{"GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0"}
+	if out, err := go_command(root, env, "vet", "./ok"); err != nil {
+		t.Fatalf("valid calls don't compile: %v\n%s", err, out)
+	}
+	if out, err := go_command(root, env, "vet", "./header"); err != nil {
+		t.Fatalf("enums of headers are typed: %v\n%s", err, out)
+	}
+	out, err := go_command(root, env, "vet", "./bad")
+	if err == nil {
+		t.Fatal("DrawArrays takes TEXTURE_2D")
+	}
+	if !strings.Contains(out, "does not implement gl.PrimitiveType") {
+		t.Fatalf("unexpected error:\n%s", out)
+	}
+}
+
+func TestVersionRangeFakeBatch(t *testing.T) {
+	root := gen_test_module(t, []*target{{
+		api:         "gl",
+		profile:     "core",
+		version:     "4.5",
+		min_version: "4.4",
+		output:      "gl",
+		backend:     "nocgo",
+		fake:        true,
+		batch:       []string{"glViewport", "glClipControl"},
+	}}, map[string]string{
+		"use/use.go": `package use
+
+import "gltest/gl"
+
+func Calls(g gl.GL, buf *gl.CommandBuffer) {
+	g.Viewport(0, 0, 1, 1)
+	g.ClipControl(gl.LOWER_LEFT, gl.ZERO_TO_ONE)
+	buf.Viewport(0, 0, 1, 1)
+	buf.ClipControl(gl.LOWER_LEFT, gl.ZERO_TO_ONE)
+	Calls(&gl.Fake{}, buf)
+	Calls(gl.Default{}, buf)
+}
+`,
+	})
+	env := []string{"GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0"}
+	if out, err := go_command(root, env, "vet", "-tags", "gl45", "./use"); err != nil {
+		t.Fatalf("commands of gl45 are missing: %v\n%s", err, out)
+	}
+	out, err := go_command(root, env, "vet", "./use")
+	if err == nil {
+		t.Fatal("commands of gl45 are built without tag gl45")
+	}
+	if !strings.Contains(out, "ClipControl") || strings.Contains(out, "Viewport") {
+		t.Fatalf("unexpected error:\n%s", out)
+	}
+}
+
+func TestGoNameCollisions(t *testing.T) {
+	header := filepath.Join(t.TempDir(), "vendor.h")
+	err := os.WriteFile(header, []byte(`#ifndef GL_VENDOR_thing
+#define GL_VENDOR_thing 1
+#define GL_foo_bar 0x9990
+#define GL_Foo_bar 0x9991
+GLAPI void APIENTRY glVendorThing (void);
+GLAPI void APIENTRY glvendorThing (void);
+#endif
+`), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := load_registry("res/gl.xml," + header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = generate(registry, &target{
+		api:        "gl",
+		profile:    "core",
+		version:    "3.2",
+		extensions: []string{"GL_VENDOR_thing"},
+		pkg:        "gl",
+		output:     filepath.Join(t.TempDir(), "gl"),
+	})
+	if err == nil {
+		t.Fatal("colliding names are generated")
+	}
+	for _, want := range []string{
+		"enum GL_Foo_bar and enum GL_foo_bar are both named Foo_bar",
+		"command glVendorThing and command glvendorThing are both named VendorThing",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q is not reported:\n%v", want, err)
+		}
+	}
+}
+
+func TestHelperDocs(t *testing.T) {
+	root := gen_test_module(t, []*target{{
+		api:     "gl",
+		profile: "compatibility",
+		version: "4.5",
+		output:  "gl",
+		strings: true,
+		slices:  true,
+	}}, map[string]string{})
+	read := func(name string) string {
+		src, err := os.ReadFile(filepath.Join(root, "gl", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(src)
+	}
+	helpers, commands := read("helpers.go"), read("commands.go")
+	for _, want := range []string{
+		"// GetString calls glGetString:\n//\n//\tconst GLubyte * glGetString(GLenum name)\n",
+		"// Uniform4fv calls glUniform4fv:\n",
+		"//   - buffer: group FeedbackElement, len size\n//\n// Deprecated: removed from the core profile by GL_VERSION_3_2.\nfunc (ctx *Context) FeedbackBuffer(",
+	} {
+		if !strings.Contains(helpers, want) {
+			t.Errorf("helpers.go doesn't contain %q", want)
+		}
+	}
+	want := "// GetStringPtr calls glGetString:\n//\n//\tconst GLubyte * glGetString(GLenum name)\n//\n// GetString wraps it"
+	if !strings.Contains(commands, want) {
+		t.Errorf("commands.go doesn't contain %q", want)
+	}
+}
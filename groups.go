@@ -0,0 +1,201 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// group_info is a registry group used as Go type of enum params. Groups of
+// Enum params are interfaces implemented by the types of their enums, so an
+// enum of several groups is accepted by all of them.
+type group_info struct {
+	name     string
+	basetype string
+	members  []string
+	iface    bool
+}
+
+func exported_name(s string) string {
+	b := []byte(s)
+	b[0] = byte(unicode.ToUpper(rune(b[0])))
+	return string(b)
+}
+
+func enum_basetype(ptype string) string {
+	switch ptype {
+	case "GLenum":
+		return "Enum"
+	case "GLbitfield":
+		return "Bitfield"
+	}
+	return ""
+}
+
+// resolve_enum_groups returns the groups of params that become Go types,
+// groups which are not defined in the registry, have no member in the
+// selected enums, or are listed in t.raw_enum_groups fall back to the raw
+// Enum or Bitfield type. Enum params without group type take AnyEnum, which
+// accepts every enum, and results of groups of params stay Enum.
+func resolve_enum_groups(registry *glxml_registry, t *target, commands_list []string, commands_map map[string]command_info, is_enums map[string]bool) map[string]*group_info {
+	if !t.typed_enums {
+		return nil
+	}
+	raw := make(map[string]bool, len(t.raw_enum_groups))
+	for _, name := range t.raw_enum_groups {
+		raw[name] = true
+	}
+	names := make(map[string]bool, len(commands_list))
+	for _, command := range commands_list {
+		names[kill_gl(command)] = true
+	}
+	for ctype := range go_rawtype_map {
+		names[go_typename(ctype)] = true
+	}
+	basetypes := make(map[string]string)
+	params := make(map[string]bool)
+	use := func(group string, ptype string) {
+		basetype := enum_basetype(ptype)
+		if group == "" || raw[group] || basetype == "" {
+			return
+		}
+		if b, ok := basetypes[group]; ok && b != basetype {
+			raw[group] = true
+			return
+		}
+		basetypes[group] = basetype
+	}
+	for _, command := range commands_list {
+		info := commands_map[command]
+		use(info.retgroup, info.rettype)
+		for _, p := range info.params {
+			use(p.group, p.ptype)
+			params[p.group] = true
+		}
+	}
+	groups := make(map[string]*group_info)
+	for _, group := range registry.groups.group {
+		basetype, ok := basetypes[group.name]
+		if !ok || raw[group.name] {
+			continue
+		}
+		var members []string
+		for _, e := range group.enum {
+			if is_enums[e.name] {
+				members = append(members, e.name)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		name := exported_name(group.name)
+		if names[name] {
+			name += "Enum"
+		}
+		groups[group.name] = &group_info{
+			name:     name,
+			basetype: basetype,
+			members:  members,
+			iface:    basetype == "Enum" && params[group.name],
+		}
+	}
+	for _, command := range commands_list {
+		info := commands_map[command]
+		if g, ok := groups[info.retgroup]; ok && enum_basetype(info.rettype) == g.basetype && !g.iface {
+			info.retgotype = g.name
+		}
+		for i, p := range info.params {
+			if g, ok := groups[p.group]; ok && enum_basetype(p.ptype) == g.basetype {
+				info.params[i].gotype = g.name
+				info.params[i].enum_iface = g.iface
+			} else if enum_basetype(p.ptype) == "Enum" {
+				info.params[i].gotype = "AnyEnum"
+				info.params[i].enum_iface = true
+			}
+		}
+		commands_map[command] = info
+	}
+	return groups
+}
+
+// gen_go_group_type declares the Go types of groups and the types of enums
+// of sets of groups, which are the types of their enums. Enum implements
+// every group, it passes values unknown to the registry.
+func gen_go_group_type(groups map[string]*group_info, sets map[string][]string) string {
+	if groups == nil {
+		return ""
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return groups[names[i]].name < groups[names[j]].name
+	})
+	s := "\n// AnyEnum is the type of enum params without group, it is implemented\n"
+	s += "// by Enum and the types of enums.\n"
+	s += "type AnyEnum interface {\n\tenum() Enum\n}\n"
+	s += "\nfunc (e Enum) enum() Enum { return e }\n"
+	types := ""
+	for _, name := range names {
+		g := groups[name]
+		if !g.iface {
+			types += "\t" + g.name + " " + g.basetype + "\n"
+			continue
+		}
+		s += "\n// " + g.name + " is implemented by the enums of group " + name + ".\n"
+		s += "type " + g.name + " interface {\n\tAnyEnum\n\tis_" + g.name + "()\n}\n"
+		s += "\nfunc (Enum) is_" + g.name + "() {}\n"
+	}
+	if types != "" {
+		s += "\ntype (\n" + types + ")\n"
+	}
+	set_names := make([]string, 0, len(sets))
+	for name := range sets {
+		set_names = append(set_names, name)
+	}
+	sort.Strings(set_names)
+	for _, name := range set_names {
+		s += "\ntype " + name + " Enum\n"
+		s += "\nfunc (e " + name + ") enum() Enum { return Enum(e) }\n"
+		for _, group := range sets[name] {
+			s += "func (" + name + ") is_" + group + "() {}\n"
+		}
+	}
+	return s
+}
+
+// group_enum_types maps Go names of enums to their Go types. Enums of groups
+// of Enum params are typed by the set of those groups, like
+// enum_EnableCap_TextureTarget, which sets maps to the group types it
+// implements. Other enums are typed by their group if they belong to exactly
+// one, enums of several Bitfield groups stay untyped so they can be used for
+// all of them.
+func group_enum_types(groups map[string]*group_info) (map[string]string, map[string][]string) {
+	count := make(map[string]int)
+	types := make(map[string]string)
+	ifaces := make(map[string][]string)
+	for _, g := range groups {
+		for _, e := range g.members {
+			if g.iface {
+				ifaces[kill_gl(e)] = append(ifaces[kill_gl(e)], g.name)
+				continue
+			}
+			count[kill_gl(e)]++
+			types[kill_gl(e)] = g.name
+		}
+	}
+	for e, n := range count {
+		if n > 1 {
+			delete(types, e)
+		}
+	}
+	sets := make(map[string][]string)
+	for e, names := range ifaces {
+		sort.Strings(names)
+		set := "enum_" + strings.Join(names, "_")
+		sets[set] = names
+		types[e] = set
+	}
+	return types, sets
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	header_ifndef_re   = regexp.MustCompile(`^#\s*ifndef\s+(\w+)`)
+	header_if_re       = regexp.MustCompile(`^#\s*if`)
+	header_endif_re    = regexp.MustCompile(`^#\s*endif`)
+	header_define_re   = regexp.MustCompile(`^#\s*define\s+(GL_\w+)\s+(\S+)`)
+	header_value_re    = regexp.MustCompile(`^(-?(?:0[xX][0-9A-Fa-f]+|\d+))([uUlL]*)$`)
+	header_command_re  = regexp.MustCompile(`^(?:GLAPI|GL_APICALL|WINGDIAPI)\s+(.*?)\s*\b\w*APIENTRY\s+(gl\w+)\s*\((.*)\)\s*;`)
+	header_funcptr_re  = regexp.MustCompile(`\(\s*\w*APIENTRY\s*\*\s*(\w+)\s*\)`)
+	header_name_re     = regexp.MustCompile(`(\w+)\s*(\[\w*\])?\s*$`)
+	header_ident_re    = regexp.MustCompile(`\w+`)
+	header_apientry_re = regexp.MustCompile(`\w*APIENTRY\s*`)
+	header_version_re  = regexp.MustCompile(`^GL_(ES_)?VERSION_(ES_CM_)?(\d+)_(\d+)$`)
+)
+
+// header_enums_comment marks the enums block of a header, headers don't tell
+// bits from values, so its enums stay untyped with typed enums.
+const header_enums_comment = "untyped enums of a C header"
+
+// header_ptype returns the GL type in C declaration text, like GLchar in
+// const GLchar *name.
+func header_ptype(text string) string {
+	for _, ident := range header_ident_re.FindAllString(text, -1) {
+		if strings.HasPrefix(ident, "GL") {
+			return ident
+		}
+	}
+	return ""
+}
+
+// header_feature returns the api and number of a feature guard like
+// GL_VERSION_4_6 or GL_ES_VERSION_3_2, it returns "" for extensions.
+func header_feature(guard string) (string, string) {
+	m := header_version_re.FindStringSubmatch(guard)
+	switch {
+	case m == nil:
+		return "", ""
+	case m[2] != "":
+		return "gles1", m[3] + "." + m[4]
+	case m[1] != "":
+		return "gles2", m[3] + "." + m[4]
+	}
+	return "gl", m[3] + "." + m[4]
+}
+
+// load_header parses enums, commands and typedefs of a Khronos C header like
+// glext.h or gl2ext.h. Blocks guarded by #ifndef GL_VERSION_x_y become
+// features and blocks of other guards become extensions supported by all
+// APIs. Headers don't tell profiles apart, so nothing is removed.
+func load_header(path string) (*glxml_registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	registry := new(glxml_registry)
+	var (
+		enums   = registry_enums{comment: header_enums_comment}
+		guards  []string
+		pending string
+		decl    string
+		// khronos types require the include of khrplatform.h
+		khrplatform bool
+		comment     bool
+		// feature of declarations without guards, like in gl.h
+		open     = -1
+		features = make(map[string]int)
+		exts     = make(map[string]int)
+	)
+	// block returns the require of the innermost feature or extension guard
+	block := func() (*registry_feature_require, *registry_extensions_extension_require) {
+		for i := len(guards) - 1; i >= 0; i-- {
+			if j, ok := features[guards[i]]; ok {
+				return &registry.feature[j].require[0], nil
+			}
+			if j, ok := exts[guards[i]]; ok {
+				return nil, &registry.extensions.extension[j].require[0]
+			}
+		}
+		if open >= 0 {
+			return &registry.feature[open].require[0], nil
+		}
+		return nil, nil
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment {
+			i := strings.Index(line, "*/")
+			if i < 0 {
+				continue
+			}
+			line = line[i+2:]
+			comment = false
+		}
+		line, comment = strip_c_comments(line)
+		line = strings.TrimSpace(line)
+		if decl != "" {
+			line = decl + " " + line
+			decl = ""
+		}
+		if m := header_ifndef_re.FindStringSubmatch(line); m != nil {
+			guards = append(guards, m[1])
+			pending = m[1]
+			continue
+		}
+		if header_if_re.MatchString(line) {
+			guards = append(guards, "")
+			pending = ""
+			continue
+		}
+		if header_endif_re.MatchString(line) {
+			if len(guards) != 0 {
+				guards = guards[:len(guards)-1]
+			}
+			pending = ""
+			continue
+		}
+		if m := header_define_re.FindStringSubmatch(line); m != nil {
+			api, number := header_feature(m[1])
+			if m[1] != pending && m[2] == "1" && api != "" {
+				// only the first version defined without guard gets the
+				// declarations without guards
+				if freq, ereq := block(); open < 0 && freq == nil && ereq == nil {
+					open = len(registry.feature)
+					registry.feature = append(registry.feature, registry_feature{
+						api:     api,
+						name:    m[1],
+						number:  number,
+						require: []registry_feature_require{{}},
+					})
+				}
+				continue
+			}
+			if m[1] == pending && m[2] == "1" {
+				// #ifndef GL_X followed by #define GL_X 1 starts a block
+				pending = ""
+				if api != "" {
+					features[m[1]] = len(registry.feature)
+					registry.feature = append(registry.feature, registry_feature{
+						api:     api,
+						name:    m[1],
+						number:  number,
+						require: []registry_feature_require{{}},
+					})
+				} else if strings.Count(m[1], "_") >= 2 {
+					exts[m[1]] = len(registry.extensions.extension)
+					registry.extensions.extension = append(registry.extensions.extension, registry_extensions_extension{
+						name:      m[1],
+						supported: "gl|glcore|gles1|gles2",
+						require:   []registry_extensions_extension_require{{}},
+					})
+				}
+				continue
+			}
+			v := header_value_re.FindStringSubmatch(m[2])
+			if v == nil {
+				continue
+			}
+			enum := registry_enums_enum{name: m[1], value: v[1]}
+			if suffix := strings.ToLower(v[2]); suffix == "ull" || suffix == "u" {
+				enum.type_ = suffix
+			}
+			enums.enum = append(enums.enum, enum)
+			freq, ereq := block()
+			if freq != nil {
+				freq.enum = append(freq.enum, registry_feature_require_enum{name: m[1]})
+			} else if ereq != nil {
+				ereq.enum = append(ereq.enum, registry_extensions_extension_require_enum{name: m[1]})
+			}
+			continue
+		}
+		pending = ""
+		if strings.HasPrefix(line, "typedef") || strings.HasPrefix(line, "GLAPI") || strings.HasPrefix(line, "GL_APICALL") || strings.HasPrefix(line, "WINGDIAPI") {
+			if !strings.HasSuffix(line, ";") {
+				decl = line
+				continue
+			}
+		}
+		if strings.HasPrefix(line, "typedef") {
+			// PFN typedefs of commands are not types
+			if strings.Contains(line, "APIENTRYP") {
+				continue
+			}
+			name := ""
+			if m := header_funcptr_re.FindStringSubmatch(line); m != nil {
+				name = m[1]
+			} else if m := header_name_re.FindStringSubmatch(strings.TrimSuffix(line, ";")); m != nil {
+				name = m[1]
+			}
+			if !strings.HasPrefix(name, "GL") {
+				continue
+			}
+			// like gl.xml, function pointer types don't use APIENTRY macros
+			line = header_apientry_re.ReplaceAllString(line, "")
+			type_ := registry_types_type{name: name, text: line}
+			if strings.Contains(line, "khronos_") {
+				type_.requires = "khrplatform"
+				if !khrplatform {
+					khrplatform = true
+					registry.types.type_ = append(registry.types.type_, registry_types_type{
+						name: "khrplatform",
+						text: "#include <KHR/khrplatform.h>",
+					})
+				}
+			}
+			registry.types.type_ = append(registry.types.type_, type_)
+			continue
+		}
+		m := header_command_re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var command registry_commands_command
+		command.proto.name = m[2]
+		command.proto.text = m[1] + " " + m[2]
+		command.proto.ptype = header_ptype(m[1])
+		if params := strings.TrimSpace(m[3]); params != "void" && params != "" {
+			for _, text := range strings.Split(params, ",") {
+				text = strings.TrimSpace(text)
+				name := header_name_re.FindStringSubmatch(text)
+				if name == nil {
+					continue
+				}
+				command.param = append(command.param, registry_commands_command_param{
+					name:  name[1],
+					ptype: header_ptype(strings.TrimSuffix(text, name[0])),
+					text:  text,
+				})
+			}
+		}
+		registry.commands.command = append(registry.commands.command, command)
+		freq, ereq := block()
+		if freq != nil {
+			freq.command = append(freq.command, registry_feature_require_command{name: m[2]})
+		} else if ereq != nil {
+			ereq.command = append(ereq.command, registry_extensions_extension_require_command{name: m[2]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	registry.enums = append(registry.enums, enums)
+	return registry, nil
+}
+
+// strip_c_comments removes comments from line, it reports whether a block
+// comment continues on the next line.
+func strip_c_comments(line string) (string, bool) {
+	for {
+		i := strings.Index(line, "/*")
+		j := strings.Index(line, "//")
+		if j >= 0 && (i < 0 || j < i) {
+			return line[:j], false
+		}
+		if i < 0 {
+			return line, false
+		}
+		k := strings.Index(line[i+2:], "*/")
+		if k < 0 {
+			return line[:i], true
+		}
+		line = line[:i] + " " + line[i+2+k+2:]
+	}
+}
+
+// merge_registry adds types, groups, enums, commands, features and
+// extensions of src which are not in dst to dst.
+func merge_registry(dst *glxml_registry, src *glxml_registry) {
+	groups := make(map[string]bool)
+	for _, g := range dst.groups.group {
+		groups[g.name] = true
+	}
+	for _, g := range src.groups.group {
+		if !groups[g.name] {
+			groups[g.name] = true
+			dst.groups.group = append(dst.groups.group, g)
+		}
+	}
+	types := make(map[string]bool)
+	for _, t := range dst.types.type_ {
+		types[t.name] = true
+	}
+	for _, t := range src.types.type_ {
+		if !types[t.name] {
+			types[t.name] = true
+			dst.types.type_ = append(dst.types.type_, t)
+		}
+	}
+	enum_names := make(map[string]bool)
+	for _, enums := range dst.enums {
+		for _, e := range enums.enum {
+			enum_names[e.name] = true
+		}
+	}
+	for _, enums := range src.enums {
+		var added []registry_enums_enum
+		for _, e := range enums.enum {
+			if !enum_names[e.name] {
+				enum_names[e.name] = true
+				added = append(added, e)
+			}
+		}
+		if len(added) != 0 {
+			enums.enum = added
+			dst.enums = append(dst.enums, enums)
+		}
+	}
+	commands := make(map[string]bool)
+	for _, c := range dst.commands.command {
+		commands[c.proto.name] = true
+	}
+	for _, c := range src.commands.command {
+		if !commands[c.proto.name] {
+			commands[c.proto.name] = true
+			dst.commands.command = append(dst.commands.command, c)
+		}
+	}
+	features := make(map[string]bool)
+	for _, f := range dst.feature {
+		features[f.name] = true
+	}
+	for _, f := range src.feature {
+		if !features[f.name] {
+			dst.feature = append(dst.feature, f)
+		}
+	}
+	extensions := make(map[string]bool)
+	for _, e := range dst.extensions.extension {
+		extensions[e.name] = true
+	}
+	for _, e := range src.extensions.extension {
+		if !extensions[e.name] {
+			dst.extensions.extension = append(dst.extensions.extension, e)
+		}
+	}
+}
+
+// load_registry loads and merges comma separated inputs, gl.xml files and
+// C headers ending with .h, earlier inputs take precedence.
+func load_registry(inputs string) (*glxml_registry, error) {
+	var registry *glxml_registry
+	for _, input := range strings.Split(inputs, ",") {
+		var (
+			src *glxml_registry
+			err error
+		)
+		if strings.HasSuffix(input, ".h") {
+			src, err = load_header(input)
+		} else {
+			src, err = load_glxml(input)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if registry == nil {
+			registry = src
+		} else {
+			merge_registry(registry, src)
+		}
+	}
+	return registry, nil
+}
@@ -3,33 +3,128 @@ package main
 import (
 	"flag"
 	"path/filepath"
+	"strings"
 )
 
 func main() {
 	var (
-		optInput   string
-		optOutput  string
-		optAPI     string
-		optProfile string
-		optVersion string
+		optInput      string
+		optOutput     string
+		optPackage    string
+		optAPI        string
+		optProfile    string
+		optVersion    string
+		optMinVersion string
+		optExtensions string
+		optConfig     string
+		optTypedEnums bool
+		optRawGroups  string
+		optSlices     bool
+		optStrings    bool
+		optDebug      bool
+		optTrace      bool
+		optEnumString bool
+		optBackend    string
+		optFake       bool
+		optNoLoader   bool
+		optBatch      string
+		optAnalyzer   bool
+		optUsedBy     string
+		optFakecgo    string
 	)
-	flag.StringVar(&optInput, "input", "res/gl.xml", "input path of gl.xml")
-	flag.StringVar(&optOutput, "output", "gl/gl.go", "output path of gl.go")
+	flag.StringVar(&optInput, "input", "res/gl.xml", "comma separated input paths of gl.xml and Khronos C headers like glext.h, earlier inputs take precedence")
+	flag.StringVar(&optOutput, "output", "gl", "output directory of generated package, a path ending with .go like gl/gl.go selects its directory")
+	flag.StringVar(&optPackage, "package", "gl", "package name of generated package")
 	flag.StringVar(&optAPI, "api", "gl", "GL API")
-	flag.StringVar(&optProfile, "profile", "core", "GL profile[core|compatibility]")
-	flag.StringVar(&optVersion, "version", "3.2", "GL version")
+	flag.StringVar(&optProfile, "profile", "", "GL profile[core|compatibility|common], core for gl and common for gles1 by default")
+	flag.StringVar(&optVersion, "version", "3.2", "GL version, latest for the latest version of api, or a range like 3.3-4.6 or 3.3-latest like -min-version")
+	flag.StringVar(&optMinVersion, "min-version", "", "GL version built without version tags, additions of later versions up to -version are built with tags like gl41")
+	flag.StringVar(&optExtensions, "extensions", "", "comma separated GL extensions")
+	flag.BoolVar(&optTypedEnums, "typed-enums", false, "use Go types of registry groups for enum params")
+	flag.StringVar(&optRawGroups, "raw-enum-groups", "", "comma separated groups which use the raw Enum type with -typed-enums")
+	flag.BoolVar(&optSlices, "slices", false, "generate wrappers taking slices for params with len, the raw pointer wrappers get suffix Ptr")
+	flag.BoolVar(&optStrings, "strings", false, "generate wrappers taking and returning Go strings, the raw pointer wrappers get suffix Ptr")
+	flag.BoolVar(&optDebug, "debug", false, "generate wrappers checking glGetError for build tag gldebug")
+	flag.BoolVar(&optTrace, "trace", false, "generate wrappers calling the tracer installed by SetTracer")
+	flag.BoolVar(&optEnumString, "enum-strings", false, "generate EnumString and EnumStringIn returning names of enums")
+	flag.StringVar(&optBackend, "backend", "cgo", "backend[cgo|nocgo], nocgo calls GL without cgo on linux/amd64 and linux/arm64")
+	flag.BoolVar(&optFake, "fake", false, "generate interface GL of commands and Fake implementing it for tests")
+	flag.BoolVar(&optNoLoader, "no-loader", false, "don't link the GL library and generate Init, commands are loaded by InitWithProcAddr or NewContext")
+	flag.StringVar(&optBatch, "batch", "", "comma separated commands recorded by CommandBuffer, all for every command without result and pointer params")
+	flag.BoolVar(&optAnalyzer, "analyzer", false, "generate package glversion with an analyzer reporting the GL version and extensions required by calls")
+	flag.StringVar(&optFakecgo, "fakecgo", "", "output directory of package fakecgo shared by nocgo packages, fakecgo next to -output by default")
+	flag.StringVar(&optUsedBy, "used-by", "", "directory of Go files, generate only the commands and enums they use")
+	flag.StringVar(&optConfig, "config", "", "path of genglgo.json, generate all targets in it")
 	flag.Parse()
 	if !flag.Parsed() || flag.NArg() != 0 {
 		panic("error flags")
 	}
-	if optProfile != "" && optProfile != "core" && optProfile != "compatibility" {
-		panic("invalid profile")
+	var targets []*target
+	if optConfig != "" {
+		input, config_targets, err := load_config(optConfig)
+		if err != nil {
+			panic(err)
+		}
+		if input != "" {
+			optInput = input
+		}
+		targets = config_targets
+	} else {
+		outpath, err := filepath.Abs(output_dir(optOutput))
+		if err != nil {
+			panic(err)
+		}
+		t := &target{
+			api:         optAPI,
+			profile:     optProfile,
+			version:     optVersion,
+			min_version: optMinVersion,
+			pkg:         optPackage,
+			output:      outpath,
+
+			typed_enums: optTypedEnums,
+			slices:      optSlices,
+			strings:     optStrings,
+			debug:       optDebug,
+			trace:       optTrace,
+
+			enum_strings: optEnumString,
+			backend:      optBackend,
+			fake:         optFake,
+			no_loader:    optNoLoader,
+			analyzer:     optAnalyzer,
+			used_by:      optUsedBy,
+		}
+		if optFakecgo != "" {
+			t.fakecgo, err = filepath.Abs(optFakecgo)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if optExtensions != "" {
+			t.extensions = strings.Split(optExtensions, ",")
+		}
+		if optBatch != "" {
+			t.batch = strings.Split(optBatch, ",")
+		}
+		if optRawGroups != "" {
+			t.raw_enum_groups = strings.Split(optRawGroups, ",")
+		}
+		targets = append(targets, t)
+	}
+	for _, t := range targets {
+		if t.profile == "" {
+			t.profile = default_profile(t.api)
+		}
+		if t.profile != "" && t.profile != "core" && t.profile != "compatibility" && t.profile != "common" {
+			panic("invalid profile: " + t.profile)
+		}
 	}
-	outpath, err := filepath.Abs(optOutput)
+	registry, err := load_registry(optInput)
 	if err != nil {
 		panic(err)
 	}
-	if err := generate(optInput, optAPI, optProfile, optVersion, outpath); err != nil {
+	if err := generate_targets(registry, targets); err != nil {
 		panic(err)
 	}
 }
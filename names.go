@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// go_reserved_names are names which params can't use besides keywords and
+// predeclared identifiers: packages imported by generated files, the
+// receiver of Context methods and package identifiers used by wrappers.
+var go_reserved_names = map[string]bool{
+	"C":       true,
+	"unsafe":  true,
+	"errors":  true,
+	"fmt":     true,
+	"math":    true,
+	"reflect": true,
+	"sync":    true,
+	"atomic":  true,
+	"time":    true,
+
+	"ctx":             true,
+	"default_context": true,
+	"tracer":          true,
+	"tracer_":         true,
+	"trace_begin":     true,
+	"c_boolean":       true,
+	"c_string":        true,
+	"bool_word":       true,
+}
+
+// go_generated_names are exported names declared by generated files, which
+// enums, commands and types can't use.
+var go_generated_names = []string{
+	"API_NAME",
+	"API_VERSION",
+	"Context",
+	"NewContext",
+	"Init",
+	"InitWithProcAddr",
+	"InitWithProcAddrC",
+	"Version",
+	"Features",
+	"Has",
+	"AnyEnum",
+	"Error",
+	"ErrorHandler",
+	"Tracer",
+	"SetTracer",
+	"EnumString",
+	"EnumStringIn",
+	"CommandBuffer",
+	"NewCommandBuffer",
+	"GL",
+	"Default",
+	"Fake",
+	"FakeCall",
+}
+
+// save_go_kw renames w by suffix _ if it is a Go keyword, a predeclared
+// identifier or in go_reserved_names.
+func save_go_kw(w string) string {
+	if token.IsKeyword(w) || types.Universe.Lookup(w) != nil || go_reserved_names[w] {
+		return w + "_"
+	}
+	return w
+}
+
+// add_go_name records that goname is generated for name in names.
+func add_go_name(names map[string][]string, goname string, name string) {
+	for _, other := range names[goname] {
+		if other == name {
+			return
+		}
+	}
+	names[goname] = append(names[goname], name)
+}
+
+// check_go_names returns an error if Go names of enums, commands and types
+// collide with each other, within one kind or with go_generated_names.
+// names maps Go names to the names they are generated for, grouped by kind.
+func check_go_names(names map[string]map[string][]string) error {
+	owners := make(map[string]string)
+	for _, name := range go_generated_names {
+		owners[name] = "generated " + name
+	}
+	kinds := make([]string, 0, len(names))
+	for kind := range names {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	var errs []string
+	for _, kind := range kinds {
+		gonames := make([]string, 0, len(names[kind]))
+		for goname := range names[kind] {
+			gonames = append(gonames, goname)
+		}
+		sort.Strings(gonames)
+		for _, goname := range gonames {
+			origins := append([]string(nil), names[kind][goname]...)
+			sort.Strings(origins)
+			for i := range origins {
+				origins[i] = kind + " " + origins[i]
+			}
+			switch len(origins) {
+			case 1:
+			case 2:
+				errs = append(errs, fmt.Sprintf("%s and %s are both named %s", origins[0], origins[1], goname))
+			default:
+				errs = append(errs, fmt.Sprintf("%s are all named %s", strings.Join(origins, ", "), goname))
+			}
+			owner := origins[0]
+			if other, ok := owners[goname]; ok {
+				errs = append(errs, fmt.Sprintf("%s and %s are both named %s", other, owner, goname))
+				continue
+			}
+			owners[goname] = owner
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return fmt.Errorf("Go name collisions:\n\t%s", strings.Join(errs, "\n\t"))
+}
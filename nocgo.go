@@ -0,0 +1,1011 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nocgo_go_min and nocgo_go_max are the minor versions of the Go releases
+// whose runtime matches the hooks of fakecgo, which are pulled from runtime
+// internals by linkname. the runtime keeps them for outside packages since
+// go1.23 (go.dev/issue/67401), later releases are added once tested.
+const (
+	nocgo_go_min = 23
+	nocgo_go_max = 27
+)
+
+// the nocgo backend calls GL through Go assembly trampolines on linux/amd64
+// and linux/arm64, runtime.cgocall switches to the system stack and package
+// fakecgo takes the place of runtime/cgo when cgo is disabled.
+var nocgo_templates = []string{
+	`
+import (
+	_ "unsafe"
+)
+
+// libc functions used by the runtime hooks in fakecgo_linux_*.s
+
+//go:cgo_import_dynamic glgo_libc_malloc malloc "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_free free "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_abort abort "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_setenv setenv "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_unsetenv unsetenv "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_sigfillset sigfillset "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_pthread_sigmask pthread_sigmask "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_pthread_create pthread_create "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_pthread_detach pthread_detach "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_pthread_attr_init pthread_attr_init "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_pthread_attr_getstacksize pthread_attr_getstacksize "libc.so.6"
+//go:cgo_import_dynamic glgo_libc_pthread_attr_destroy pthread_attr_destroy "libc.so.6"
+//go:cgo_import_dynamic _ _ "libc.so.6"
+
+// the runtime creates threads by pthread and keeps g in the TLS of libc
+// when iscgo is set, like runtime/cgo does.
+
+//go:linkname _iscgo runtime.iscgo
+var _iscgo = true
+
+//go:linkname _set_crosscall2 runtime.set_crosscall2
+var _set_crosscall2 = set_crosscall2
+
+// C never calls back into Go
+func set_crosscall2() {}
+
+//go:linkname x_cgo_init_trampoline x_cgo_init_trampoline
+//go:linkname _cgo_init _cgo_init
+var x_cgo_init_trampoline byte
+var _cgo_init = &x_cgo_init_trampoline
+
+//go:linkname x_cgo_thread_start_trampoline x_cgo_thread_start_trampoline
+//go:linkname _cgo_thread_start _cgo_thread_start
+var x_cgo_thread_start_trampoline byte
+var _cgo_thread_start = &x_cgo_thread_start_trampoline
+
+//go:linkname x_cgo_notify_runtime_init_done_trampoline x_cgo_notify_runtime_init_done_trampoline
+//go:linkname _cgo_notify_runtime_init_done _cgo_notify_runtime_init_done
+var x_cgo_notify_runtime_init_done_trampoline byte
+var _cgo_notify_runtime_init_done = &x_cgo_notify_runtime_init_done_trampoline
+
+//go:linkname x_cgo_setenv_trampoline x_cgo_setenv_trampoline
+//go:linkname _cgo_setenv runtime._cgo_setenv
+var x_cgo_setenv_trampoline byte
+var _cgo_setenv = &x_cgo_setenv_trampoline
+
+//go:linkname x_cgo_unsetenv_trampoline x_cgo_unsetenv_trampoline
+//go:linkname _cgo_unsetenv runtime._cgo_unsetenv
+var x_cgo_unsetenv_trampoline byte
+var _cgo_unsetenv = &x_cgo_unsetenv_trampoline
+
+//go:linkname _cgo_pthread_key_created _cgo_pthread_key_created
+var x_cgo_pthread_key_created uintptr
+var _cgo_pthread_key_created = &x_cgo_pthread_key_created
+`,
+	`#include "textflag.h"
+
+// void x_cgo_init(G *g, void (*setg)(void*))
+TEXT x_cgo_init_trampoline(SB), NOSPLIT|NOFRAME, $0
+	// 0(SP) size, 8(SP) attr, 72(SP) BX, 80(SP) BP
+	SUBQ	$88, SP
+	MOVQ	BP, 80(SP)
+	LEAQ	80(SP), BP
+	MOVQ	BX, 72(SP)
+	MOVQ	DI, BX
+	MOVQ	SI, setg_gcc<>(SB)
+	LEAQ	8(SP), DI
+	CALL	glgo_libc_pthread_attr_init(SB)
+	LEAQ	8(SP), DI
+	LEAQ	0(SP), SI
+	CALL	glgo_libc_pthread_attr_getstacksize(SB)
+	LEAQ	8(SP), DI
+	CALL	glgo_libc_pthread_attr_destroy(SB)
+	// g->stacklo = &size - size + 4096
+	LEAQ	0(SP), AX
+	SUBQ	0(SP), AX
+	ADDQ	$4096, AX
+	MOVQ	AX, 0(BX)
+	MOVQ	72(SP), BX
+	MOVQ	80(SP), BP
+	ADDQ	$88, SP
+	RET
+
+// void x_cgo_thread_start(ThreadStart *ts)
+TEXT x_cgo_thread_start_trampoline(SB), NOSPLIT|NOFRAME, $0
+	// 0(SP) pthread_t, 8(SP) size, 16(SP) attr, 80(SP) ign, 208(SP) oset,
+	// 336(SP) R12, 344(SP) BX, 352(SP) BP
+	SUBQ	$360, SP
+	MOVQ	BP, 352(SP)
+	LEAQ	352(SP), BP
+	MOVQ	BX, 344(SP)
+	MOVQ	R12, 336(SP)
+	MOVQ	DI, BX
+	// ts is on the stack of the caller, threadentry frees the copy
+	MOVQ	$24, DI
+	CALL	glgo_libc_malloc(SB)
+	TESTQ	AX, AX
+	JZ	fail
+	MOVQ	0(BX), CX
+	MOVQ	CX, 0(AX)
+	MOVQ	8(BX), CX
+	MOVQ	CX, 8(AX)
+	MOVQ	16(BX), CX
+	MOVQ	CX, 16(AX)
+	MOVQ	AX, R12
+	LEAQ	80(SP), DI
+	CALL	glgo_libc_sigfillset(SB)
+	MOVQ	$2, DI // SIG_SETMASK
+	LEAQ	80(SP), SI
+	LEAQ	208(SP), DX
+	CALL	glgo_libc_pthread_sigmask(SB)
+	LEAQ	16(SP), DI
+	CALL	glgo_libc_pthread_attr_init(SB)
+	LEAQ	16(SP), DI
+	LEAQ	8(SP), SI
+	CALL	glgo_libc_pthread_attr_getstacksize(SB)
+	// ts->g->stackhi = size, mstart sets the rest
+	MOVQ	0(R12), AX
+	MOVQ	8(SP), CX
+	MOVQ	CX, 8(AX)
+	LEAQ	0(SP), DI
+	LEAQ	16(SP), SI
+	MOVQ	$threadentry<>(SB), DX
+	MOVQ	R12, CX
+	CALL	glgo_libc_pthread_create(SB)
+	MOVQ	AX, BX
+	TESTQ	BX, BX
+	JNZ	created
+	MOVQ	0(SP), DI
+	CALL	glgo_libc_pthread_detach(SB)
+created:
+	LEAQ	16(SP), DI
+	CALL	glgo_libc_pthread_attr_destroy(SB)
+	MOVQ	$2, DI
+	LEAQ	208(SP), SI
+	MOVQ	$0, DX
+	CALL	glgo_libc_pthread_sigmask(SB)
+	TESTQ	BX, BX
+	JNZ	fail
+	MOVQ	336(SP), R12
+	MOVQ	344(SP), BX
+	MOVQ	352(SP), BP
+	ADDQ	$360, SP
+	RET
+fail:
+	CALL	glgo_libc_abort(SB)
+	RET
+
+// void *threadentry(ThreadStart *ts)
+TEXT threadentry<>(SB), NOSPLIT|NOFRAME, $0
+	// Go code doesn't keep callee-saved registers
+	SUBQ	$56, SP
+	MOVQ	BP, 48(SP)
+	LEAQ	48(SP), BP
+	MOVQ	BX, 40(SP)
+	MOVQ	R12, 32(SP)
+	MOVQ	R13, 24(SP)
+	MOVQ	R14, 16(SP)
+	MOVQ	R15, 8(SP)
+	MOVQ	0(DI), BX
+	MOVQ	16(DI), R12
+	CALL	glgo_libc_free(SB)
+	MOVQ	BX, DI
+	MOVQ	setg_gcc<>(SB), AX
+	CALL	AX
+	CALL	R12
+	MOVQ	8(SP), R15
+	MOVQ	16(SP), R14
+	MOVQ	24(SP), R13
+	MOVQ	32(SP), R12
+	MOVQ	40(SP), BX
+	MOVQ	48(SP), BP
+	ADDQ	$56, SP
+	XORQ	AX, AX
+	RET
+
+// void x_cgo_notify_runtime_init_done(void)
+TEXT x_cgo_notify_runtime_init_done_trampoline(SB), NOSPLIT|NOFRAME, $0
+	RET
+
+// void x_cgo_setenv(char **arg)
+TEXT x_cgo_setenv_trampoline(SB), NOSPLIT|NOFRAME, $0
+	SUBQ	$8, SP
+	MOVQ	8(DI), SI
+	MOVQ	0(DI), DI
+	MOVQ	$1, DX
+	CALL	glgo_libc_setenv(SB)
+	ADDQ	$8, SP
+	RET
+
+// void x_cgo_unsetenv(char **arg)
+TEXT x_cgo_unsetenv_trampoline(SB), NOSPLIT|NOFRAME, $0
+	SUBQ	$8, SP
+	MOVQ	0(DI), DI
+	CALL	glgo_libc_unsetenv(SB)
+	ADDQ	$8, SP
+	RET
+
+GLOBL setg_gcc<>(SB), NOPTR, $8
+`,
+	`#include "textflag.h"
+
+// void x_cgo_init(G *g, void (*setg)(void*))
+TEXT x_cgo_init_trampoline(SB), NOSPLIT|NOFRAME, $0
+	// 0(RSP) FP, 8(RSP) LR, 16(RSP) R19, 24(RSP) size, 32(RSP) attr
+	SUB	$96, RSP
+	MOVD	R29, 0(RSP)
+	MOVD	R30, 8(RSP)
+	MOVD	R19, 16(RSP)
+	MOVD	RSP, R29
+	MOVD	R0, R19
+	MOVD	R1, setg_gcc<>(SB)
+	ADD	$32, RSP, R0
+	BL	glgo_libc_pthread_attr_init(SB)
+	ADD	$32, RSP, R0
+	ADD	$24, RSP, R1
+	BL	glgo_libc_pthread_attr_getstacksize(SB)
+	ADD	$32, RSP, R0
+	BL	glgo_libc_pthread_attr_destroy(SB)
+	// g->stacklo = &size - size + 4096
+	ADD	$24, RSP, R0
+	MOVD	24(RSP), R1
+	SUB	R1, R0
+	ADD	$4096, R0
+	MOVD	R0, 0(R19)
+	MOVD	16(RSP), R19
+	MOVD	8(RSP), R30
+	MOVD	0(RSP), R29
+	ADD	$96, RSP
+	RET
+
+// void x_cgo_thread_start(ThreadStart *ts)
+TEXT x_cgo_thread_start_trampoline(SB), NOSPLIT|NOFRAME, $0
+	// 0(RSP) FP, 8(RSP) LR, 16(RSP) R19, 24(RSP) R20, 32(RSP) pthread_t,
+	// 40(RSP) size, 48(RSP) attr, 112(RSP) ign, 240(RSP) oset
+	SUB	$368, RSP
+	MOVD	R29, 0(RSP)
+	MOVD	R30, 8(RSP)
+	MOVD	R19, 16(RSP)
+	MOVD	R20, 24(RSP)
+	MOVD	RSP, R29
+	MOVD	R0, R19
+	// ts is on the stack of the caller, threadentry frees the copy
+	MOVD	$24, R0
+	BL	glgo_libc_malloc(SB)
+	CBZ	R0, fail
+	MOVD	0(R19), R1
+	MOVD	R1, 0(R0)
+	MOVD	8(R19), R1
+	MOVD	R1, 8(R0)
+	MOVD	16(R19), R1
+	MOVD	R1, 16(R0)
+	MOVD	R0, R20
+	ADD	$112, RSP, R0
+	BL	glgo_libc_sigfillset(SB)
+	MOVD	$2, R0 // SIG_SETMASK
+	ADD	$112, RSP, R1
+	ADD	$240, RSP, R2
+	BL	glgo_libc_pthread_sigmask(SB)
+	ADD	$48, RSP, R0
+	BL	glgo_libc_pthread_attr_init(SB)
+	ADD	$48, RSP, R0
+	ADD	$40, RSP, R1
+	BL	glgo_libc_pthread_attr_getstacksize(SB)
+	// ts->g->stackhi = size, mstart sets the rest
+	MOVD	0(R20), R0
+	MOVD	40(RSP), R1
+	MOVD	R1, 8(R0)
+	ADD	$32, RSP, R0
+	ADD	$48, RSP, R1
+	MOVD	$threadentry<>(SB), R2
+	MOVD	R20, R3
+	BL	glgo_libc_pthread_create(SB)
+	MOVD	R0, R19
+	CBNZ	R19, created
+	MOVD	32(RSP), R0
+	BL	glgo_libc_pthread_detach(SB)
+created:
+	ADD	$48, RSP, R0
+	BL	glgo_libc_pthread_attr_destroy(SB)
+	MOVD	$2, R0
+	ADD	$240, RSP, R1
+	MOVD	$0, R2
+	BL	glgo_libc_pthread_sigmask(SB)
+	CBNZ	R19, fail
+	MOVD	24(RSP), R20
+	MOVD	16(RSP), R19
+	MOVD	8(RSP), R30
+	MOVD	0(RSP), R29
+	ADD	$368, RSP
+	RET
+fail:
+	BL	glgo_libc_abort(SB)
+	RET
+
+// void *threadentry(ThreadStart *ts)
+TEXT threadentry<>(SB), NOSPLIT|NOFRAME, $0
+	// Go code doesn't keep callee-saved registers
+	SUB	$96, RSP
+	MOVD	R29, 0(RSP)
+	MOVD	R30, 8(RSP)
+	STP	(R19, R20), 16(RSP)
+	STP	(R21, R22), 32(RSP)
+	STP	(R23, R24), 48(RSP)
+	STP	(R25, R26), 64(RSP)
+	STP	(R27, g), 80(RSP)
+	MOVD	RSP, R29
+	MOVD	0(R0), R19
+	MOVD	16(R0), R20
+	BL	glgo_libc_free(SB)
+	MOVD	R19, R0
+	MOVD	setg_gcc<>(SB), R1
+	BL	(R1)
+	BL	(R20)
+	LDP	80(RSP), (R27, g)
+	LDP	64(RSP), (R25, R26)
+	LDP	48(RSP), (R23, R24)
+	LDP	32(RSP), (R21, R22)
+	LDP	16(RSP), (R19, R20)
+	MOVD	8(RSP), R30
+	MOVD	0(RSP), R29
+	ADD	$96, RSP
+	MOVD	$0, R0
+	RET
+
+// void x_cgo_notify_runtime_init_done(void)
+TEXT x_cgo_notify_runtime_init_done_trampoline(SB), NOSPLIT|NOFRAME, $0
+	RET
+
+// void x_cgo_setenv(char **arg)
+TEXT x_cgo_setenv_trampoline(SB), NOSPLIT|NOFRAME, $0
+	SUB	$16, RSP
+	MOVD	R29, 0(RSP)
+	MOVD	R30, 8(RSP)
+	MOVD	RSP, R29
+	MOVD	8(R0), R1
+	MOVD	0(R0), R0
+	MOVD	$1, R2
+	BL	glgo_libc_setenv(SB)
+	MOVD	8(RSP), R30
+	MOVD	0(RSP), R29
+	ADD	$16, RSP
+	RET
+
+// void x_cgo_unsetenv(char **arg)
+TEXT x_cgo_unsetenv_trampoline(SB), NOSPLIT|NOFRAME, $0
+	SUB	$16, RSP
+	MOVD	R29, 0(RSP)
+	MOVD	R30, 8(RSP)
+	MOVD	RSP, R29
+	MOVD	0(R0), R0
+	BL	glgo_libc_unsetenv(SB)
+	MOVD	8(RSP), R30
+	MOVD	0(RSP), R29
+	ADD	$16, RSP
+	RET
+
+GLOBL setg_gcc<>(SB), NOPTR, $8
+`,
+	`
+import (
+	_ "runtime/cgo"
+)
+`,
+	`
+//go:cgo_import_dynamic glgo_libc_dlopen dlopen "libdl.so.2"
+//go:cgo_import_dynamic glgo_libc_dlsym dlsym "libdl.so.2"
+//go:cgo_import_dynamic _ _ "libdl.so.2"
+
+// addresses of trampolines in call_linux_*.s
+var (
+	dlopen_addr uintptr
+	dlsym_addr  uintptr
+)
+
+//go:linkname runtime_cgocall runtime.cgocall
+//go:noescape
+func runtime_cgocall(fn uintptr, arg unsafe.Pointer) int32
+
+const rtld_now_global = 0x102
+
+func dlopen(name string) unsafe.Pointer {
+	return call_p_pi(dlopen_addr, unsafe.Pointer(unsafe.StringData(name+"\x00")), rtld_now_global)
+}
+
+func dlsym(lib unsafe.Pointer, name string) uintptr {
+	return uintptr(call_p_pp(dlsym_addr, lib, unsafe.Pointer(unsafe.StringData(name+"\x00"))))
+}
+
+func go_string(p unsafe.Pointer) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for *(*byte)(unsafe.Add(p, n)) != 0 {
+		n++
+	}
+	return string(unsafe.Slice((*byte)(p), n))
+}
+
+func c_string(s string) *byte {
+	return unsafe.StringData(s + "\x00")
+}
+`,
+	`
+var get_proc_address uintptr
+
+func load_library() bool {
+	lib := dlopen("libGL.so.1")
+	if lib == nil {
+		return false
+	}
+	get_proc_address = dlsym(lib, "glXGetProcAddressARB")
+	return get_proc_address != 0
+}
+
+func load_proc(name string) unsafe.Pointer {
+	return call_p_p(get_proc_address, unsafe.Pointer(c_string(name)))
+}
+`,
+	`
+var (
+	get_proc_address uintptr
+	lib_gles         unsafe.Pointer
+)
+
+func load_library() bool {
+	lib := dlopen("libEGL.so.1")
+	if lib == nil {
+		return false
+	}
+	get_proc_address = dlsym(lib, "eglGetProcAddress")
+	lib_gles = dlopen("lib%[1]s.so%[2]s")
+	return get_proc_address != 0 && lib_gles != nil
+}
+
+func load_proc(name string) unsafe.Pointer {
+	cname := unsafe.Pointer(c_string(name))
+	p := call_p_p(get_proc_address, cname)
+	if p == nil {
+		p = call_p_pp(dlsym_addr, lib_gles, cname)
+	}
+	return p
+}
+`,
+	`
+// Init loads commands of the package functions from the current GL context,
+// it returns -1 if the GL library can't be loaded, 1 + the index of the
+// first missing command, or 0.
+func Init() int {
+	if !load_library() {
+		return -1
+	}
+	return default_context.load(load_proc)
+}
+`,
+	`
+func c_boolean(b Boolean) uintptr {
+	if b {
+		return 1
+	}
+	return 0
+}
+`,
+	`
+var tramp_batch_addr uintptr
+
+// frame_batch holds the n words of records of CommandBuffer, a record is
+// the address of the replay stub of the signature of a command, the
+// command and its arguments.
+type frame_batch struct {
+	words unsafe.Pointer
+	n     uintptr
+}
+
+// call_batch calls the commands recorded in words in one call.
+func call_batch(words []uint64) {
+	f := frame_batch{words: unsafe.Pointer(unsafe.SliceData(words)), n: uintptr(len(words))}
+	runtime_cgocall(tramp_batch_addr, unsafe.Pointer(&f))
+}
+`,
+	`
+// tramp_batch calls the replay stubs of the records of frame_batch in DI,
+// each stub advances BX to the next record.
+TEXT tramp_batch<>(SB), NOSPLIT|NOFRAME, $0
+	SUBQ	$24, SP
+	MOVQ	BP, 16(SP)
+	LEAQ	16(SP), BP
+	MOVQ	BX, 8(SP)
+	MOVQ	R12, 0(SP)
+	MOVQ	0(DI), BX
+	MOVQ	8(DI), R12
+	LEAQ	(BX)(R12*8), R12
+loop:
+	CMPQ	BX, R12
+	JAE	done
+	MOVQ	0(BX), AX
+	CALL	AX
+	JMP	loop
+done:
+	MOVQ	0(SP), R12
+	MOVQ	8(SP), BX
+	MOVQ	16(SP), BP
+	ADDQ	$24, SP
+	RET
+GLOBL ·tramp_batch_addr(SB), RODATA, $8
+DATA ·tramp_batch_addr(SB)/8, $tramp_batch<>(SB)
+`,
+	`
+// tramp_batch calls the replay stubs of the records of frame_batch in R0,
+// each stub advances R19 to the next record.
+TEXT tramp_batch<>(SB), NOSPLIT|NOFRAME, $0
+	SUB	$32, RSP
+	MOVD	R29, 0(RSP)
+	MOVD	R30, 8(RSP)
+	MOVD	R19, 16(RSP)
+	MOVD	R20, 24(RSP)
+	MOVD	RSP, R29
+	MOVD	0(R0), R19
+	MOVD	8(R0), R20
+	ADD	R20<<3, R19, R20
+loop:
+	CMP	R20, R19
+	BHS	done
+	MOVD	0(R19), R9
+	BL	(R9)
+	B	loop
+done:
+	MOVD	24(RSP), R20
+	MOVD	16(RSP), R19
+	MOVD	8(RSP), R30
+	MOVD	0(RSP), R29
+	ADD	$32, RSP
+	RET
+GLOBL ·tramp_batch_addr(SB), RODATA, $8
+DATA ·tramp_batch_addr(SB)/8, $tramp_batch<>(SB)
+`,
+}
+
+// platforms of nocgo packages
+var nocgo_platforms = []string{"linux,amd64", "linux,arm64"}
+
+// headers of files of package fakecgo, the runtime hooks of nocgo packages
+var fakecgo_headers = []string{
+	`// +build %s
+
+// Package fakecgo takes the place of runtime/cgo for GL packages generated
+// with the nocgo backend. The runtime hooks can be defined once in a binary,
+// so all nocgo packages import this package.
+package fakecgo
+
+// generate by genglgo[https://github.com/vizee/genglgo]
+// updated at: %s
+`,
+	`// +build %s
+
+package fakecgo
+
+// generate by genglgo[https://github.com/vizee/genglgo]
+// updated at: %s
+`,
+	`//go:build !cgo
+
+// generate by genglgo[https://github.com/vizee/genglgo]
+// updated at: %s
+
+`,
+}
+
+// gen_nocgo_runtime writes package fakecgo with the runtime hooks of nocgo
+// packages to dir, with cgo enabled it imports runtime/cgo instead.
+func gen_nocgo_runtime(dir string) error {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return err
+	}
+	if err := remove_generated(dir); err != nil {
+		return err
+	}
+	updated := time.Now().Format("2006-01-02 15:04:05")
+	header := fmt.Sprintf(fakecgo_headers[0], build_tags(nocgo_platforms, "!cgo"), updated)
+	if err := write_file(filepath.Join(dir, "fakecgo.go"), header, nocgo_templates[0]); err != nil {
+		return err
+	}
+	header = fmt.Sprintf(fakecgo_headers[1], build_tags(nocgo_platforms, "cgo"), updated)
+	if err := write_file(filepath.Join(dir, "cgo.go"), header, nocgo_templates[3]); err != nil {
+		return err
+	}
+	asm_header := fmt.Sprintf(fakecgo_headers[2], updated)
+	if err := write_file(filepath.Join(dir, "fakecgo_linux_amd64.s"), asm_header, nocgo_templates[1]); err != nil {
+		return err
+	}
+	return write_file(filepath.Join(dir, "fakecgo_linux_arm64.s"), asm_header, nocgo_templates[2])
+}
+
+// signatures of the functions called by the loader
+var nocgo_loader_signatures = [...]string{
+	"p_p",
+	"p_pi",
+	"p_pp",
+}
+
+var (
+	amd64_int_regs = [...]string{"DI", "SI", "DX", "CX", "R8", "R9"}
+	arm64_int_regs = [...]string{"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7"}
+)
+
+const max_float_regs = 8
+
+// nocgo_class classifies Go types of params and results by how they are
+// passed to C: i for integers, p for pointers, f for float and d for double.
+func nocgo_class(gotype string) byte {
+	if strings.HasPrefix(gotype, "*") || gotype == "unsafe.Pointer" {
+		return 'p'
+	}
+	rawtype := gotype
+	for name, t := range go_rawtype_map {
+		if go_typename(name) == gotype {
+			rawtype = t
+			break
+		}
+	}
+	switch rawtype {
+	case "float32":
+		return 'f'
+	case "float64":
+		return 'd'
+	case "unsafe.Pointer":
+		return 'p'
+	}
+	return 'i'
+}
+
+// nocgo_signature is like v_iif for void f(int, int, float), results of
+// commands without params have no underscore.
+func nocgo_signature(info command_info) string {
+	sig := "v"
+	if info.rettype != "void" {
+		sig = string(nocgo_class(ret_gotype(info)))
+	}
+	if len(info.params) == 0 {
+		return sig
+	}
+	sig += "_"
+	for _, p := range info.params {
+		sig += string(nocgo_class(param_gotype(p)))
+	}
+	return sig
+}
+
+func split_signature(sig string) (byte, string) {
+	if len(sig) == 1 {
+		return sig[0], ""
+	}
+	return sig[0], sig[2:]
+}
+
+// gen_go_nocgo_arg converts Go argument name to the type of its frame slot.
+func gen_go_nocgo_arg(name string, gotype string) string {
+	switch nocgo_class(gotype) {
+	case 'p':
+		return "unsafe.Pointer(" + name + ")"
+	case 'f':
+		return "uintptr(math.Float32bits(float32(" + name + ")))"
+	case 'd':
+		return "uintptr(math.Float64bits(float64(" + name + ")))"
+	}
+	if gotype == "Boolean" {
+		return "c_boolean(" + name + ")"
+	}
+	return "uintptr(" + name + ")"
+}
+
+// gen_go_nocgo_ret converts the result of call to Go type rettype.
+func gen_go_nocgo_ret(call string, rettype string) string {
+	switch nocgo_class(rettype) {
+	case 'f':
+		return rettype + "(math.Float32frombits(uint32(" + call + ")))"
+	case 'd':
+		return rettype + "(math.Float64frombits(uint64(" + call + ")))"
+	}
+	if rettype == "Boolean" {
+		return "Boolean(uint8(" + call + ") != 0)"
+	}
+	if strings.HasPrefix(rettype, "*") {
+		return "(" + rettype + ")(" + call + ")"
+	}
+	return rettype + "(" + call + ")"
+}
+
+// gen_go_nocgo_call generates the frame and call function of sig, the frame
+// holds the function, its arguments and its result in 8 bytes slots.
+func gen_go_nocgo_call(sig string) string {
+	ret, args := split_signature(sig)
+	slot := func(c byte) string {
+		if c == 'p' {
+			return "unsafe.Pointer"
+		}
+		return "uintptr"
+	}
+	s := "\nvar tramp_" + sig + "_addr uintptr\n"
+	s += "\ntype frame_" + sig + " struct {\n"
+	s += "\tfn uintptr\n"
+	params := "fn uintptr"
+	fields := "fn: fn"
+	for i := 0; i < len(args); i++ {
+		name := fmt.Sprintf("a%d", i)
+		s += "\t" + name + " " + slot(args[i]) + "\n"
+		params += ", " + name + " " + slot(args[i])
+		fields += ", " + name + ": " + name
+	}
+	if ret != 'v' {
+		s += "\tr " + slot(ret) + "\n"
+	}
+	s += "}\n"
+	s += "\nfunc call_" + sig + "(" + params + ") "
+	if ret != 'v' {
+		s += slot(ret) + " "
+	}
+	s += "{\n"
+	s += "\tf := frame_" + sig + "{" + fields + "}\n"
+	s += "\truntime_cgocall(tramp_" + sig + "_addr, unsafe.Pointer(&f))\n"
+	if ret != 'v' {
+		s += "\treturn f.r\n"
+	}
+	s += "}\n"
+	return s
+}
+
+func gen_asm_trampoline_addr(name string) string {
+	s := "GLOBL ·" + name + "_addr(SB), RODATA, $8\n"
+	s += "DATA ·" + name + "_addr(SB)/8, $" + name + "<>(SB)\n"
+	return s
+}
+
+// amd64_arg_loads returns the loads of args at offset base of the frame in
+// BX into registers of the System V ABI, and the offsets of stack args.
+func amd64_arg_loads(args string, base int) (string, []int) {
+	var (
+		loads  string
+		stack  []int
+		nint   int
+		nfloat int
+	)
+	for i := 0; i < len(args); i++ {
+		off := base + 8*i
+		switch {
+		case args[i] == 'f' && nfloat < max_float_regs:
+			loads += fmt.Sprintf("\tMOVSS\t%d(BX), X%d\n", off, nfloat)
+			nfloat++
+		case args[i] == 'd' && nfloat < max_float_regs:
+			loads += fmt.Sprintf("\tMOVSD\t%d(BX), X%d\n", off, nfloat)
+			nfloat++
+		case (args[i] == 'i' || args[i] == 'p') && nint < len(amd64_int_regs):
+			loads += fmt.Sprintf("\tMOVQ\t%d(BX), %s\n", off, amd64_int_regs[nint])
+			nint++
+		default:
+			stack = append(stack, off)
+		}
+	}
+	return loads, stack
+}
+
+// gen_asm_amd64_call generates the trampoline of sig for the System V ABI,
+// it is called by runtime.cgocall with the frame in DI.
+func gen_asm_amd64_call(sig string) string {
+	ret, args := split_signature(sig)
+	loads, stack := amd64_arg_loads(args, 8)
+	// stack args, BX and BP, SP is 16 bytes aligned at CALL
+	frame := (len(stack)*8+15)&^15 + 24
+	s := "\nTEXT tramp_" + sig + "<>(SB), NOSPLIT|NOFRAME, $0\n"
+	s += fmt.Sprintf("\tSUBQ\t$%d, SP\n", frame)
+	s += fmt.Sprintf("\tMOVQ\tBP, %d(SP)\n", frame-8)
+	s += fmt.Sprintf("\tLEAQ\t%d(SP), BP\n", frame-8)
+	s += fmt.Sprintf("\tMOVQ\tBX, %d(SP)\n", frame-16)
+	s += "\tMOVQ\tDI, BX\n"
+	for i, off := range stack {
+		s += fmt.Sprintf("\tMOVQ\t%d(BX), AX\n", off)
+		s += fmt.Sprintf("\tMOVQ\tAX, %d(SP)\n", i*8)
+	}
+	s += loads
+	s += "\tMOVQ\t0(BX), R11\n"
+	s += "\tCALL\tR11\n"
+	off := 8 * (len(args) + 1)
+	switch ret {
+	case 'f':
+		s += fmt.Sprintf("\tMOVSS\tX0, %d(BX)\n", off)
+	case 'd':
+		s += fmt.Sprintf("\tMOVSD\tX0, %d(BX)\n", off)
+	case 'i', 'p':
+		s += fmt.Sprintf("\tMOVQ\tAX, %d(BX)\n", off)
+	}
+	s += fmt.Sprintf("\tMOVQ\t%d(SP), BX\n", frame-16)
+	s += fmt.Sprintf("\tMOVQ\t%d(SP), BP\n", frame-8)
+	s += fmt.Sprintf("\tADDQ\t$%d, SP\n", frame)
+	s += "\tRET\n"
+	return s + gen_asm_trampoline_addr("tramp_"+sig)
+}
+
+// gen_asm_amd64_replay generates the replay stub of sig called by
+// tramp_batch with the record [stub, fn, args...] in BX, it calls fn and
+// advances BX to the next record.
+func gen_asm_amd64_replay(sig string) string {
+	_, args := split_signature(sig)
+	loads, stack := amd64_arg_loads(args, 16)
+	// stack args, SP is 16 bytes aligned at CALL
+	frame := (len(stack)*8+15)&^15 + 8
+	s := "\nTEXT replay_" + sig + "<>(SB), NOSPLIT|NOFRAME, $0\n"
+	s += fmt.Sprintf("\tSUBQ\t$%d, SP\n", frame)
+	for i, off := range stack {
+		s += fmt.Sprintf("\tMOVQ\t%d(BX), AX\n", off)
+		s += fmt.Sprintf("\tMOVQ\tAX, %d(SP)\n", i*8)
+	}
+	s += loads
+	s += "\tMOVQ\t8(BX), R11\n"
+	s += "\tCALL\tR11\n"
+	s += fmt.Sprintf("\tADDQ\t$%d, BX\n", 8*(len(args)+2))
+	s += fmt.Sprintf("\tADDQ\t$%d, SP\n", frame)
+	s += "\tRET\n"
+	return s + gen_asm_trampoline_addr("replay_"+sig)
+}
+
+// arm64_arg_loads returns the loads of args at offset base of the frame in
+// R19 into registers of AAPCS64, and the offsets of stack args.
+func arm64_arg_loads(args string, base int) (string, []int) {
+	var (
+		loads  string
+		stack  []int
+		nint   int
+		nfloat int
+	)
+	for i := 0; i < len(args); i++ {
+		off := base + 8*i
+		switch {
+		case args[i] == 'f' && nfloat < max_float_regs:
+			loads += fmt.Sprintf("\tFMOVS\t%d(R19), F%d\n", off, nfloat)
+			nfloat++
+		case args[i] == 'd' && nfloat < max_float_regs:
+			loads += fmt.Sprintf("\tFMOVD\t%d(R19), F%d\n", off, nfloat)
+			nfloat++
+		case (args[i] == 'i' || args[i] == 'p') && nint < len(arm64_int_regs):
+			loads += fmt.Sprintf("\tMOVD\t%d(R19), %s\n", off, arm64_int_regs[nint])
+			nint++
+		default:
+			stack = append(stack, off)
+		}
+	}
+	return loads, stack
+}
+
+// gen_asm_arm64_call generates the trampoline of sig for AAPCS64, it is
+// called by runtime.cgocall with the frame in R0.
+func gen_asm_arm64_call(sig string) string {
+	ret, args := split_signature(sig)
+	loads, stack := arm64_arg_loads(args, 8)
+	// stack args, frame record and R19
+	args_size := (len(stack)*8 + 15) &^ 15
+	frame := args_size + 32
+	s := "\nTEXT tramp_" + sig + "<>(SB), NOSPLIT|NOFRAME, $0\n"
+	s += fmt.Sprintf("\tSUB\t$%d, RSP\n", frame)
+	s += fmt.Sprintf("\tMOVD\tR29, %d(RSP)\n", args_size)
+	s += fmt.Sprintf("\tMOVD\tR30, %d(RSP)\n", args_size+8)
+	s += fmt.Sprintf("\tMOVD\tR19, %d(RSP)\n", args_size+16)
+	s += fmt.Sprintf("\tADD\t$%d, RSP, R29\n", args_size)
+	s += "\tMOVD\tR0, R19\n"
+	for i, off := range stack {
+		s += fmt.Sprintf("\tMOVD\t%d(R19), R9\n", off)
+		s += fmt.Sprintf("\tMOVD\tR9, %d(RSP)\n", i*8)
+	}
+	s += loads
+	s += "\tMOVD\t0(R19), R9\n"
+	s += "\tBL\t(R9)\n"
+	off := 8 * (len(args) + 1)
+	switch ret {
+	case 'f':
+		s += fmt.Sprintf("\tFMOVS\tF0, %d(R19)\n", off)
+	case 'd':
+		s += fmt.Sprintf("\tFMOVD\tF0, %d(R19)\n", off)
+	case 'i', 'p':
+		s += fmt.Sprintf("\tMOVD\tR0, %d(R19)\n", off)
+	}
+	s += fmt.Sprintf("\tMOVD\t%d(RSP), R19\n", args_size+16)
+	s += fmt.Sprintf("\tMOVD\t%d(RSP), R30\n", args_size+8)
+	s += fmt.Sprintf("\tMOVD\t%d(RSP), R29\n", args_size)
+	s += fmt.Sprintf("\tADD\t$%d, RSP\n", frame)
+	s += "\tRET\n"
+	return s + gen_asm_trampoline_addr("tramp_"+sig)
+}
+
+// gen_asm_arm64_replay generates the replay stub of sig called by
+// tramp_batch with the record [stub, fn, args...] in R19, it calls fn and
+// advances R19 to the next record.
+func gen_asm_arm64_replay(sig string) string {
+	_, args := split_signature(sig)
+	loads, stack := arm64_arg_loads(args, 16)
+	// stack args and frame record
+	args_size := (len(stack)*8 + 15) &^ 15
+	frame := args_size + 16
+	s := "\nTEXT replay_" + sig + "<>(SB), NOSPLIT|NOFRAME, $0\n"
+	s += fmt.Sprintf("\tSUB\t$%d, RSP\n", frame)
+	s += fmt.Sprintf("\tMOVD\tR29, %d(RSP)\n", args_size)
+	s += fmt.Sprintf("\tMOVD\tR30, %d(RSP)\n", args_size+8)
+	s += fmt.Sprintf("\tADD\t$%d, RSP, R29\n", args_size)
+	for i, off := range stack {
+		s += fmt.Sprintf("\tMOVD\t%d(R19), R9\n", off)
+		s += fmt.Sprintf("\tMOVD\tR9, %d(RSP)\n", i*8)
+	}
+	s += loads
+	s += "\tMOVD\t8(R19), R9\n"
+	s += "\tBL\t(R9)\n"
+	s += fmt.Sprintf("\tADD\t$%d, R19\n", 8*(len(args)+2))
+	s += fmt.Sprintf("\tMOVD\t%d(RSP), R30\n", args_size+8)
+	s += fmt.Sprintf("\tMOVD\t%d(RSP), R29\n", args_size)
+	s += fmt.Sprintf("\tADD\t$%d, RSP\n", frame)
+	s += "\tRET\n"
+	return s + gen_asm_trampoline_addr("replay_"+sig)
+}
+
+// gen_asm_dl_trampolines generates trampolines jumping to dlopen and dlsym,
+// Go code takes their addresses.
+func gen_asm_dl_trampolines() string {
+	s := ""
+	for _, name := range [...]string{"dlopen", "dlsym"} {
+		s += "\nTEXT " + name + "<>(SB), NOSPLIT|NOFRAME, $0\n"
+		s += "\tJMP\tglgo_libc_" + name + "(SB)\n"
+		s += gen_asm_trampoline_addr(name)
+	}
+	return s
+}
+
+// gen_nocgo_calls generates call.go and the trampolines of both archs for
+// signatures, and the replay stubs of CommandBuffer for replays.
+func gen_nocgo_calls(signatures map[string]bool, replays map[string]bool) (string, string, string) {
+	for _, sig := range nocgo_loader_signatures {
+		signatures[sig] = true
+	}
+	sigs := make([]string, 0, len(signatures))
+	for sig := range signatures {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+	calls := nocgo_templates[4]
+	amd64 := "#include \"textflag.h\"\n"
+	amd64 += gen_asm_dl_trampolines()
+	arm64 := "#include \"textflag.h\"\n"
+	arm64 += gen_asm_dl_trampolines()
+	for _, sig := range sigs {
+		calls += gen_go_nocgo_call(sig)
+		amd64 += gen_asm_amd64_call(sig)
+		arm64 += gen_asm_arm64_call(sig)
+	}
+	if len(replays) == 0 {
+		return calls, amd64, arm64
+	}
+	sigs = sigs[:0]
+	for sig := range replays {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+	calls += nocgo_templates[9]
+	amd64 += nocgo_templates[10]
+	arm64 += nocgo_templates[11]
+	for _, sig := range sigs {
+		calls += "\nvar replay_" + sig + "_addr uintptr\n"
+		amd64 += gen_asm_amd64_replay(sig)
+		arm64 += gen_asm_arm64_replay(sig)
+	}
+	return calls, amd64, arm64
+}
+
+// gen_go_nocgo_loader generates the library loader of api and Init loading
+// commands into the default context.
+func gen_go_nocgo_loader(api string) string {
+	var s string
+	if lib, ok := gles_library_map[api]; ok {
+		s = fmt.Sprintf(nocgo_templates[6], lib[0], lib[1])
+	} else {
+		s = nocgo_templates[5]
+	}
+	return s + nocgo_templates[7]
+}
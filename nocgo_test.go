@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// stub_library is a GL library recording the calls of a few commands,
+// glGetString returns the version of the library for GL_VERSION and the
+// recorded calls for other names, glGetError always fails.
+const stub_library = `
+#include <stdarg.h>
+#include <stdio.h>
+#include <string.h>
+
+static char calls[4096];
+
+static void record(const char *format, ...) {
+	size_t n = strlen(calls);
+	va_list ap;
+	va_start(ap, format);
+	vsnprintf(calls + n, sizeof(calls) - n, format, ap);
+	va_end(ap);
+}
+
+static void noop(void) {}
+
+static void clear(unsigned mask) {
+	record("glClear(%#x)\n", mask);
+}
+
+static void clear_color(float r, float g, float b, float a) {
+	record("glClearColor(%g, %g, %g, %g)\n", r, g, b, a);
+}
+
+static void draw_arrays(unsigned mode, int first, int count) {
+	record("glDrawArrays(%#x, %d, %d)\n", mode, first, count);
+}
+
+static void draw_elements(unsigned mode, int count, unsigned type, const void *indices, int instancecount, int basevertex, unsigned baseinstance) {
+	record("glDrawElementsInstancedBaseVertexBaseInstance(%#x, %d, %#x, %s, %d, %d, %u)\n", mode, count, type, indices ? "indices" : "NULL", instancecount, basevertex, baseinstance);
+}
+
+static void tex_image_2d(unsigned target, int level, int internalformat, int width, int height, int border, unsigned format, unsigned type, const void *pixels) {
+	record("glTexImage2D(%#x, %d, %#x, %d, %d, %d, %#x, %#x, %d)\n", target, level, internalformat, width, height, border, format, type, *(const unsigned char *)pixels);
+}
+
+static void copy_image_sub_data(unsigned a, unsigned b, int c, int d, int e, int f, unsigned g, unsigned h, int i, int j, int k, int l, int m, int n, int o) {
+	record("glCopyImageSubData(%u, %#x, %d, %d, %d, %d, %u, %#x, %d, %d, %d, %d, %d, %d, %d)\n", a, b, c, d, e, f, g, h, i, j, k, l, m, n, o);
+}
+
+static unsigned get_error(void) {
+	return 0x0502;
+}
+
+static const char *get_string(unsigned name) {
+	return name == 0x1F02 ? VERSION : calls;
+}
+
+static void *get_proc_address(const char *name) {
+	static const struct {
+		const char *name;
+		void *proc;
+	} procs[] = {
+		{"glClear", clear},
+		{"glClearColor", clear_color},
+		{"glDrawArrays", draw_arrays},
+		{"glDrawElementsInstancedBaseVertexBaseInstance", draw_elements},
+		{"glTexImage2D", tex_image_2d},
+		{"glGetString", get_string},
+		{"glGetError", get_error},
+		{"glCopyImageSubData", copy_image_sub_data},
+	};
+	for (size_t i = 0; i < sizeof(procs) / sizeof(procs[0]); i++) {
+		if (strcmp(procs[i].name, name) == 0) {
+			return procs[i].proc;
+		}
+	}
+	return noop;
+}
+
+void *glXGetProcAddress(const char *name) {
+	return get_proc_address(name);
+}
+
+void *glXGetProcAddressARB(const char *name) {
+	return get_proc_address(name);
+}
+
+void *eglGetProcAddress(const char *name) {
+	return get_proc_address(name);
+}
+`
+
+// build_stub_library compiles stub_library reporting version to names in
+// dir.
+func build_stub_library(t testing.TB, dir string, version string, names ...string) {
+	t.Helper()
+	src := filepath.Join(dir, "stub.c")
+	if err := os.WriteFile(src, []byte(stub_library), 0664); err != nil {
+		t.Fatal(err)
+	}
+	lib := filepath.Join(dir, names[0])
+	out, err := exec.Command("gcc", "-shared", "-fPIC", "-o", lib, `-DVERSION="`+version+`"`, src).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	for _, name := range names[1:] {
+		if err := os.Link(lib, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNocgoStubLibrary(t *testing.T) {
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		t.Skip("nocgo backend isn't supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	// the runtime hooks of fakecgo break silently on other releases
+	version, err := go_command(".", nil, "env", "GOVERSION")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, version)
+	}
+	version = strings.TrimSpace(version)
+	var minor int
+	if _, err := fmt.Sscanf(version, "go1.%d", &minor); err != nil || minor < nocgo_go_min || minor > nocgo_go_max {
+		t.Fatalf("%s isn't supported by the nocgo backend, fakecgo is tested with go1.%d to go1.%d", version, nocgo_go_min, nocgo_go_max)
+	}
+	// packages of two APIs share the runtime hooks in one binary
+	root := gen_test_module(t, []*target{{
+		api:     "gl",
+		profile: "core",
+		version: "4.5",
+		output:  "gl",
+		strings: true,
+		backend: "nocgo",
+	}, {
+		api:     "gles2",
+		version: "2.0",
+		output:  "gles2",
+		strings: true,
+		backend: "nocgo",
+	}}, map[string]string{
+		"main.go": `package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"gltest/gl"
+	"gltest/gles2"
+)
+
+func main() {
+	if gl.Init() != 0 || gles2.Init() != 0 {
+		panic("Init failed")
+	}
+	pixels := []byte{7}
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	gl.ClearColor(0.25, 0.5, 0.75, 1)
+	gl.DrawElementsInstancedBaseVertexBaseInstance(gl.TRIANGLES, 6, gl.UNSIGNED_SHORT, nil, 2, 3, 4)
+	gl.TexImage2D(gl.TEXTURE_2D, 1, gl.RGBA, 2, 3, 0, gl.RGBA, gl.UNSIGNED_BYTE, unsafe.Pointer(&pixels[0]))
+	gles2.Clear(gles2.COLOR_BUFFER_BIT)
+	gles2.DrawArrays(gles2.TRIANGLES, 1, 3)
+	fmt.Print(gl.GetString(gl.RENDERER), gles2.GetString(gles2.RENDERER))
+	major, minor := gl.Version()
+	fmt.Printf("gl %d.%d\n", major, minor)
+	major, minor = gles2.Version()
+	fmt.Printf("gles2 %d.%d\n", major, minor)
+}
+`,
+	})
+	libs := filepath.Join(root, "libs")
+	if err := os.Mkdir(libs, 0775); err != nil {
+		t.Fatal(err)
+	}
+	build_stub_library(t, libs, "4.5 stub", "libGL.so.1")
+	build_stub_library(t, libs, "OpenGL ES 2.0 stub", "libEGL.so.1", "libGLESv2.so.2")
+	bin := filepath.Join(root, "stub")
+	if out, err := go_command(root, []string{"CGO_ENABLED=0"}, "build", "-o", bin, "."); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(), "LD_LIBRARY_PATH="+libs)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	want := `glClear(0x4100)
+glClearColor(0.25, 0.5, 0.75, 1)
+glDrawElementsInstancedBaseVertexBaseInstance(0x4, 6, 0x1403, NULL, 2, 3, 4)
+glTexImage2D(0xde1, 1, 0x1908, 2, 3, 0, 0x1908, 0x1401, 7)
+glClear(0x4000)
+glDrawArrays(0x4, 1, 3)
+gl 4.5
+gles2 2.0
+`
+	if string(out) != want {
+		t.Fatalf("calls:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDebugErrorLimit(t *testing.T) {
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		t.Skip("nocgo backend isn't supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	root := gen_test_module(t, []*target{{
+		api:     "gl",
+		profile: "core",
+		version: "4.5",
+		output:  "gl",
+		debug:   true,
+		backend: "nocgo",
+	}}, map[string]string{
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"gltest/gl"
+)
+
+func main() {
+	if gl.Init() != 0 {
+		panic("Init failed")
+	}
+	errors := 0
+	gl.ErrorHandler = func(err *gl.Error) {
+		errors++
+	}
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	fmt.Println(errors)
+}
+`,
+	})
+	libs := filepath.Join(root, "libs")
+	if err := os.Mkdir(libs, 0775); err != nil {
+		t.Fatal(err)
+	}
+	build_stub_library(t, libs, "4.5 stub", "libGL.so.1")
+	bin := filepath.Join(root, "debug")
+	if out, err := go_command(root, []string{"CGO_ENABLED=0"}, "build", "-tags", "gldebug", "-o", bin, "."); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(), "LD_LIBRARY_PATH="+libs)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if string(out) != "8\n" {
+		t.Fatalf("errors reported after a failing command: %s", out)
+	}
+}
+
+func TestCommandBufferStubLibrary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("stub library isn't supported on " + runtime.GOOS)
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	for _, backend := range []string{"cgo", "nocgo"} {
+		t.Run(backend, func(t *testing.T) {
+			env := []string{"CGO_ENABLED=1"}
+			if backend == "nocgo" {
+				if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+					t.Skip("nocgo backend isn't supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+				}
+				env = []string{"CGO_ENABLED=0"}
+			}
+			root := gen_test_module(t, []*target{{
+				api:     "gl",
+				profile: "core",
+				version: "4.5",
+				output:  "gl",
+				backend: backend,
+				strings: true,
+				batch:   []string{"glClear", "glClearColor", "glCopyImageSubData", "glDrawArrays"},
+			}}, map[string]string{
+				"main.go": `package main
+
+import (
+	"fmt"
+
+	"gltest/gl"
+)
+
+func main() {
+	if gl.Init() != 0 {
+		panic("Init failed")
+	}
+	buf := gl.NewCommandBuffer()
+	buf.ClearColor(0.25, 0.5, 0.75, 1)
+	buf.CopyImageSubData(1, gl.TEXTURE_2D, 2, 3, 4, 5, 6, gl.TEXTURE_3D, 7, 8, 9, 10, 11, 12, 13)
+	buf.Clear(gl.COLOR_BUFFER_BIT)
+	buf.DrawArrays(gl.TRIANGLES, 1, 3)
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+	buf.Submit()
+	buf.Submit()
+	buf.Clear(gl.STENCIL_BUFFER_BIT)
+	buf.Submit()
+	fmt.Print(gl.GetString(gl.RENDERER))
+}
+`,
+			})
+			libs := filepath.Join(root, "libs")
+			if err := os.Mkdir(libs, 0775); err != nil {
+				t.Fatal(err)
+			}
+			build_stub_library(t, libs, "4.5 stub", "libGL.so", "libGL.so.1")
+			env = append(env, "CGO_LDFLAGS=-L"+libs)
+			bin := filepath.Join(root, "buffer")
+			if out, err := go_command(root, env, "build", "-o", bin, "."); err != nil {
+				t.Fatalf("%v\n%s", err, out)
+			}
+			cmd := exec.Command(bin)
+			cmd.Env = append(os.Environ(), "LD_LIBRARY_PATH="+libs)
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%v\n%s", err, out)
+			}
+			want := `glClear(0x100)
+glClearColor(0.25, 0.5, 0.75, 1)
+glCopyImageSubData(1, 0xde1, 2, 3, 4, 5, 6, 0x806f, 7, 8, 9, 10, 11, 12, 13)
+glClear(0x4000)
+glDrawArrays(0x4, 1, 3)
+glClear(0x400)
+`
+			if string(out) != want {
+				t.Fatalf("calls:\n%s\nwant:\n%s", out, want)
+			}
+		})
+	}
+}
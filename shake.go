@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// import_path resolves the import path of directory dir by the module path
+// in the nearest go.mod, or by GOPATH.
+func import_path(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := dir; ; root = filepath.Dir(root) {
+		if module := module_path(filepath.Join(root, "go.mod")); module != "" {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			if rel == "." {
+				return module, nil
+			}
+			return module + "/" + filepath.ToSlash(rel), nil
+		}
+		if filepath.Dir(root) == root {
+			break
+		}
+	}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src") + string(filepath.Separator)
+		if strings.HasPrefix(dir, src) {
+			return filepath.ToSlash(dir[len(src):]), nil
+		}
+	}
+	return "", errors.New("can't resolve import path of " + dir)
+}
+
+// module_path returns the module path declared in go.mod at path, or "".
+func module_path(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+// scan_go_usage returns names which Go files under dir may use from the
+// package at pkgdir. Without type information methods of Context and other
+// types can't be told apart, so every selector name counts, like the
+// qualified identifiers and the identifiers of files dot importing it.
+func scan_go_usage(dir string, pkgdir string) (map[string]bool, error) {
+	path, err := import_path(pkgdir)
+	if err != nil {
+		return nil, err
+	}
+	pkgdir, err = filepath.Abs(pkgdir)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	imported := false
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			base := d.Name()
+			if name != dir && (base == "vendor" || base == "testdata" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			if abs, err := filepath.Abs(name); err == nil && abs == pkgdir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		dot := false
+		for _, spec := range file.Imports {
+			if strings.Trim(spec.Path.Value, "\"`") != path {
+				continue
+			}
+			imported = true
+			if spec.Name != nil && spec.Name.Name == "." {
+				dot = true
+			}
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.SelectorExpr:
+				used[node.Sel.Name] = true
+			case *ast.Ident:
+				if dot {
+					used[node.Name] = true
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !imported {
+		return nil, errors.New("no Go file in " + dir + " imports " + path)
+	}
+	return used, nil
+}
+
+// shake_unused removes commands and enums whose Go names are not used, the
+// names in keep are not removed.
+func shake_unused(used map[string]bool, is_commands map[string]bool, is_enums map[string]bool, keep []string) {
+	kept := make(map[string]bool, len(keep))
+	for _, name := range keep {
+		kept[name] = true
+	}
+	for command := range is_commands {
+		goname := kill_gl(command)
+		if !kept[command] && !used[goname] && !used[goname+"Ptr"] {
+			delete(is_commands, command)
+		}
+	}
+	for enum := range is_enums {
+		if !kept[enum] && !used[kill_gl(enum)] {
+			delete(is_enums, enum)
+		}
+	}
+}
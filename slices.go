@@ -0,0 +1,156 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// len attributes of params derived from len(slice): n, count, count*4
+var len_expr_re = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(\*([0-9]+))?$`)
+
+func is_integer_ctype(ptype string) bool {
+	raw, ok := go_rawtype_map[ptype]
+	return ok && (strings.HasPrefix(raw, "int") || strings.HasPrefix(raw, "uint"))
+}
+
+// is_byte_count reports whether count param p is a size in bytes, which
+// []byte of void pointers can derive. Other counts of void pointers like
+// count of glDrawElementsInstancedBaseInstance count elements of a type
+// given by another param, or the pointer is an offset into a bound buffer.
+func is_byte_count(p param_info) bool {
+	switch p.ptype {
+	case "GLsizeiptr", "GLsizeiptrARB":
+		return true
+	}
+	return p.name == "imageSize" || strings.HasSuffix(strings.ToLower(p.name), "bufsize")
+}
+
+// slice_elemtype returns the element type of slice taken by a pointer param,
+// numeric data takes slices of raw Go types like []float32 and void pointers
+// take []byte.
+func slice_elemtype(p param_info) string {
+	if strings.Count(p.ptype, "*") != 1 {
+		return ""
+	}
+	gotype := param_gotype(p)
+	if gotype == "unsafe.Pointer" && strings.Contains(p.ptype, "void") {
+		return "byte"
+	}
+	if !strings.HasPrefix(gotype, "*") || gotype == "*unsafe.Pointer" {
+		return ""
+	}
+	ctype := strings.TrimSpace(strings.Replace(strings.Replace(p.ptype, "const", "", -1), "*", "", -1))
+	switch ctype {
+	case "GLenum", "GLbitfield", "GLboolean":
+		return gotype[1:]
+	}
+	if raw, ok := go_rawtype_map[ctype]; ok && raw != "unsafe.Pointer" {
+		return raw
+	}
+	return gotype[1:]
+}
+
+func join_go_params(names []string, types []string) string {
+	s := ""
+	for i, name := range names {
+		if s != "" {
+			s += ", "
+		}
+		s += name
+		if i == len(names)-1 || types[i] != types[i+1] {
+			s += " " + types[i]
+		}
+	}
+	return s
+}
+
+// gen_go_slice_command generates a wrapper of rawname which takes slices
+// for pointer params with len attribute referring to a count param, the
+// count param is derived from len(slice). It returns "" if no param of
+// command can be a slice.
+func gen_go_slice_command(goname string, rawname string, info command_info) string {
+	index := make(map[string]int, len(info.params))
+	for i, p := range info.params {
+		index[p.name] = i
+	}
+	refs := make(map[int]int)
+	slices := make(map[int]int)
+	divs := make(map[int]string)
+	for i, p := range info.params {
+		m := len_expr_re.FindStringSubmatch(p.len_)
+		if m == nil {
+			continue
+		}
+		j, ok := index[m[1]]
+		if !ok {
+			continue
+		}
+		refs[j]++
+		elemtype := slice_elemtype(p)
+		if elemtype == "" || !is_integer_ctype(info.params[j].ptype) || info.params[j].gotype != "" {
+			continue
+		}
+		if elemtype == "byte" && (m[3] != "" || !is_byte_count(info.params[j])) {
+			continue
+		}
+		slices[i] = j
+		divs[i] = m[3]
+	}
+	counts := make(map[int]int)
+	for i, j := range slices {
+		if refs[j] != 1 {
+			delete(slices, i)
+			continue
+		}
+		counts[j] = i
+	}
+	if len(slices) == 0 {
+		return ""
+	}
+	var (
+		names []string
+		types []string
+		args  []string
+	)
+	for i, p := range info.params {
+		name := save_go_kw(p.name)
+		if j, ok := counts[i]; ok {
+			slice := save_go_kw(info.params[j].name)
+			arg := "len(" + slice + ")"
+			if divs[j] != "" && divs[j] != "1" {
+				arg += " / " + divs[j]
+			}
+			args = append(args, param_gotype(p)+"("+arg+")")
+			continue
+		}
+		if _, ok := slices[i]; ok {
+			elemtype := slice_elemtype(p)
+			names = append(names, name)
+			types = append(types, "[]"+elemtype)
+			gotype := param_gotype(p)
+			if elemtype == "byte" {
+				args = append(args, "unsafe.Pointer(unsafe.SliceData("+name+"))")
+			} else if "*"+elemtype != gotype {
+				args = append(args, "("+gotype+")(unsafe.Pointer(unsafe.SliceData("+name+")))")
+			} else {
+				args = append(args, "unsafe.SliceData("+name+")")
+			}
+			continue
+		}
+		names = append(names, name)
+		types = append(types, param_gotype(p))
+		args = append(args, name)
+	}
+	s := "\n"
+	s += "func " + goname + "(" + join_go_params(names, types) + ") "
+	call := rawname + "(" + strings.Join(args, ", ") + ")"
+	if info.rettype != "void" {
+		s += ret_gotype(info) + " {\n"
+		s += "\treturn " + call + "\n"
+	} else {
+		s += "{\n"
+		s += "\t" + call + "\n"
+	}
+	s += "}\n"
+	return s
+}
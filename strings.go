@@ -0,0 +1,244 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// len attributes of strings whose length is a param, like
+// COMPSIZE(label,length) of glObjectLabel
+var compsize_length_re = regexp.MustCompile(`^COMPSIZE\(([A-Za-z_][A-Za-z0-9_]*),([A-Za-z_][A-Za-z0-9_]*)\)$`)
+
+func is_char_ctype(ctype string) bool {
+	return ctype == "GLchar" || ctype == "GLcharARB"
+}
+
+// gen_go_string_command generates a wrapper of rawname which uses Go strings:
+//   - const GLchar * params take string and are copied to C memory, or to
+//     NUL terminated Go memory for nocgo wrappers, their length params are
+//     derived from len(s)
+//   - const GLchar *const* params take []string, or ...string as the last param
+//   - GLchar * params filled by GL with bufSize and length params return string
+//   - const GLubyte * results return string
+//
+// It returns "" if command has no string.
+func gen_go_string_command(goname string, rawname string, info command_info, nocgo bool) string {
+	index := make(map[string]int, len(info.params))
+	for i, p := range info.params {
+		index[p.name] = i
+	}
+	refs := make(map[int]int)
+	for _, p := range info.params {
+		if m := len_expr_re.FindStringSubmatch(p.len_); m != nil {
+			if j, ok := index[m[1]]; ok {
+				refs[j]++
+			}
+		}
+		if m := compsize_length_re.FindStringSubmatch(p.len_); m != nil {
+			if j, ok := index[m[2]]; ok {
+				refs[j]++
+			}
+		}
+	}
+	count_of := func(p param_info) int {
+		m := len_expr_re.FindStringSubmatch(p.len_)
+		if m == nil || m[3] != "" {
+			return -1
+		}
+		j, ok := index[m[1]]
+		if !ok || !is_integer_ctype(info.params[j].ptype) {
+			return -1
+		}
+		return j
+	}
+	// length_of returns the param of the length of string p, or -1
+	length_of := func(p param_info) int {
+		if j := count_of(p); j >= 0 {
+			return j
+		}
+		m := compsize_length_re.FindStringSubmatch(p.len_)
+		if m == nil || m[1] != p.name {
+			return -1
+		}
+		j, ok := index[m[2]]
+		if !ok || !is_integer_ctype(info.params[j].ptype) {
+			return -1
+		}
+		return j
+	}
+	const (
+		kind_none = iota
+		kind_in
+		kind_in_array
+		kind_out
+	)
+	kinds := make([]int, len(info.params))
+	hidden := make(map[int]bool)
+	args := make([]string, len(info.params))
+	out := -1
+	out_length := -1
+	for i, p := range info.params {
+		switch {
+		case p.ptype == "const GLchar *" || p.ptype == "const GLcharARB *":
+			if j := length_of(p); j >= 0 {
+				if refs[j] != 1 {
+					continue
+				}
+				hidden[j] = true
+				args[j] = param_gotype(info.params[j]) + "(len(" + save_go_kw(p.name) + "))"
+			}
+			kinds[i] = kind_in
+		case p.ptype == "const GLchar *const*" || p.ptype == "const GLchar **" || p.ptype == "const GLcharARB **":
+			j := count_of(p)
+			if j < 0 {
+				continue
+			}
+			// the optional lengths of strings
+			length := -1
+			for k, q := range info.params {
+				if k != i && count_of(q) == j {
+					length = k
+				}
+			}
+			if refs[j] > 2 || (refs[j] == 2 && (length < 0 || !strings.HasPrefix(info.params[length].ptype, "const GLint *"))) {
+				continue
+			}
+			kinds[i] = kind_in_array
+			hidden[j] = true
+			args[j] = param_gotype(info.params[j]) + "(len(" + save_go_kw(p.name) + "))"
+			if length >= 0 {
+				hidden[length] = true
+				args[length] = "nil"
+			}
+		case (p.ptype == "GLchar *" || p.ptype == "GLcharARB *") && info.rettype == "void" && out < 0:
+			j := count_of(p)
+			if j < 0 || refs[j] != 1 || info.params[j].ptype != "GLsizei" {
+				continue
+			}
+			length, ok := index["length"]
+			if !ok || info.params[length].ptype != "GLsizei *" || info.params[length].len_ != "1" {
+				continue
+			}
+			kinds[i] = kind_out
+			out = i
+			out_length = length
+			hidden[j] = true
+			hidden[length] = true
+			args[j] = param_gotype(info.params[j]) + "(len(" + save_go_kw(p.name) + "))"
+			args[length] = "&" + save_go_kw(info.params[length].name)
+		}
+	}
+	ret_string := info.rettype == "const GLubyte *" || info.rettype == "const GLchar *"
+	has_string := ret_string
+	for _, kind := range kinds {
+		if kind != kind_none {
+			has_string = true
+		}
+	}
+	if !has_string {
+		return ""
+	}
+	last := -1
+	for i := range info.params {
+		if !hidden[i] {
+			last = i
+		}
+	}
+	var (
+		names  []string
+		types  []string
+		before string
+		after  string
+	)
+	for i, p := range info.params {
+		if hidden[i] {
+			continue
+		}
+		name := save_go_kw(p.name)
+		gotype := param_gotype(p)
+		switch kinds[i] {
+		case kind_in:
+			names = append(names, name)
+			types = append(types, "string")
+			if nocgo {
+				before += "\t" + name + "_c := c_string(" + name + ")\n"
+			} else {
+				before += "\t" + name + "_c := C.CString(" + name + ")\n"
+				after += "\tC.free(unsafe.Pointer(" + name + "_c))\n"
+			}
+			args[i] = "(" + gotype + ")(unsafe.Pointer(" + name + "_c))"
+		case kind_in_array:
+			names = append(names, name)
+			if i == last {
+				types = append(types, "...string")
+			} else {
+				types = append(types, "[]string")
+			}
+			if nocgo {
+				before += "\t" + name + "_c := make([]*byte, len(" + name + "))\n"
+				before += "\tfor i, s := range " + name + " {\n"
+				before += "\t\t" + name + "_c[i] = c_string(s)\n"
+				before += "\t}\n"
+			} else {
+				before += "\t" + name + "_c := make([]*C.char, len(" + name + "))\n"
+				before += "\tfor i, s := range " + name + " {\n"
+				before += "\t\t" + name + "_c[i] = C.CString(s)\n"
+				before += "\t}\n"
+				after += "\tfor _, p := range " + name + "_c {\n"
+				after += "\t\tC.free(unsafe.Pointer(p))\n"
+				after += "\t}\n"
+			}
+			args[i] = "(" + gotype + ")(unsafe.Pointer(unsafe.SliceData(" + name + "_c)))"
+		case kind_out:
+			before += "\tvar " + save_go_kw(info.params[out_length].name) + " Sizei\n"
+			before += "\t" + name + " := make([]byte, 256)\n"
+			args[i] = "(" + gotype + ")(unsafe.Pointer(unsafe.SliceData(" + name + ")))"
+		default:
+			names = append(names, name)
+			types = append(types, gotype)
+			args[i] = name
+		}
+	}
+	call := rawname + "(" + strings.Join(args, ", ") + ")"
+	s := "\n"
+	s += "func " + goname + "(" + join_go_params(names, types) + ") "
+	switch {
+	case out >= 0:
+		name := save_go_kw(info.params[out].name)
+		length := save_go_kw(info.params[out_length].name)
+		s += "string {\n"
+		s += before
+		s += "\tfor {\n"
+		s += "\t\t" + call + "\n"
+		s += "\t\tif int(" + length + ") < len(" + name + ")-1 {\n"
+		s += "\t\t\tbreak\n"
+		s += "\t\t}\n"
+		s += "\t\t" + name + " = make([]byte, len(" + name + ")*2)\n"
+		s += "\t}\n"
+		s += after
+		s += "\treturn string(" + name + "[:" + length + "])\n"
+	case ret_string:
+		s += "string {\n"
+		s += before
+		s += "\tret_ := " + call + "\n"
+		s += after
+		if nocgo {
+			s += "\treturn go_string(unsafe.Pointer(ret_))\n"
+		} else {
+			s += "\treturn C.GoString((*C.char)(unsafe.Pointer(ret_)))\n"
+		}
+	case info.rettype != "void":
+		s += ret_gotype(info) + " {\n"
+		s += before
+		s += "\tret_ := " + call + "\n"
+		s += after
+		s += "\treturn ret_\n"
+	default:
+		s += "{\n"
+		s += before
+		s += "\t" + call + "\n"
+		s += after
+	}
+	s += "}\n"
+	return s
+}
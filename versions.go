@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gl_version is a version like 4.6, versions are compared by major and minor
+// numbers, so 4.10 is after 4.9.
+type gl_version struct {
+	major int
+	minor int
+}
+
+func parse_version(s string) (gl_version, error) {
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		return gl_version{}, errors.New("invalid version: " + s)
+	}
+	major, err := strconv.Atoi(s[:dot])
+	if err != nil || major < 0 {
+		return gl_version{}, errors.New("invalid version: " + s)
+	}
+	minor, err := strconv.Atoi(s[dot+1:])
+	if err != nil || minor < 0 {
+		return gl_version{}, errors.New("invalid version: " + s)
+	}
+	return gl_version{major, minor}, nil
+}
+
+func (v gl_version) less(w gl_version) bool {
+	return v.major < w.major || v.major == w.major && v.minor < w.minor
+}
+
+func (v gl_version) String() string {
+	return strconv.Itoa(v.major) + "." + strconv.Itoa(v.minor)
+}
+
+// select_versions resolves the version of t and its min version against the
+// features of its api. t.version is a version, latest, or a range like
+// 3.3-4.6 or 3.3-latest which selects the min version too. min is max
+// without min version.
+func select_versions(registry *glxml_registry, t *target) (min gl_version, max gl_version, err error) {
+	var versions []gl_version
+	for _, feature := range registry.feature {
+		if !is_same_api(t.api, feature.api) {
+			continue
+		}
+		v, err := parse_version(feature.number)
+		if err != nil {
+			return min, max, err
+		}
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return min, max, errors.New("no version of api " + t.api)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].less(versions[j])
+	})
+	resolve := func(s string) (gl_version, error) {
+		if s == "latest" {
+			return versions[len(versions)-1], nil
+		}
+		v, err := parse_version(s)
+		if err != nil {
+			return v, err
+		}
+		for _, w := range versions {
+			if w == v {
+				return v, nil
+			}
+		}
+		names := make([]string, len(versions))
+		for i, w := range versions {
+			names[i] = w.String()
+		}
+		return v, errors.New("version " + s + " is not a version of " + t.api + ", versions: " + strings.Join(names, " "))
+	}
+	min_spec, max_spec := t.min_version, t.version
+	if i := strings.IndexByte(t.version, '-'); i >= 0 {
+		if t.min_version != "" {
+			return min, max, errors.New("version range " + t.version + " can't be combined with min version")
+		}
+		min_spec, max_spec = t.version[:i], t.version[i+1:]
+	}
+	if max, err = resolve(max_spec); err != nil {
+		return min, max, err
+	}
+	if min_spec == "" {
+		return max, max, nil
+	}
+	if min, err = resolve(min_spec); err != nil {
+		return min, max, err
+	}
+	if max.less(min) {
+		return min, max, errors.New("min version " + min.String() + " is greater than version " + max.String())
+	}
+	return min, max, nil
+}
+
+// version_tag returns the build tag selecting version number of api, like
+// gl41 for gl 4.1 and gles31 for gles2 3.1.
+func version_tag(api string, number string) string {
+	if api == "" {
+		api = "gl"
+	}
+	return strings.TrimRight(api, "0123456789") + strings.Replace(number, ".", "", -1)
+}
+
+// version_levels returns the sorted versions of since, which maps enums and
+// commands to the versions adding them, and maps those versions to their
+// levels, 1 for the first version. Names not in since have level 0.
+func version_levels(since map[string]string) ([]string, map[string]int) {
+	var numbers []string
+	seen := make(map[string]bool)
+	for _, number := range since {
+		if !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		a, _ := parse_version(numbers[i])
+		b, _ := parse_version(numbers[j])
+		return a.less(b)
+	})
+	levels := make(map[string]int, len(numbers))
+	for i, number := range numbers {
+		levels[number] = i + 1
+	}
+	return numbers, levels
+}
+
+// version_constraint returns the build constraint of additions of
+// numbers[i], they are built with the tag of that version or any later one.
+func version_constraint(api string, numbers []string, i int) string {
+	tags := make([]string, 0, len(numbers)-i)
+	for _, number := range numbers[i:] {
+		tags = append(tags, version_tag(api, number))
+	}
+	return "\n// +build " + strings.Join(tags, " ")
+}
+
+// version_exclusive_constraint returns the build constraint of files built
+// only if level l is the highest level of the package, level 0 is built
+// without version tags.
+func version_exclusive_constraint(api string, numbers []string, l int) string {
+	var tags []string
+	if l > 0 {
+		tags = append(tags, version_tag(api, numbers[l-1]))
+	}
+	for _, number := range numbers[l:] {
+		tags = append(tags, "!"+version_tag(api, number))
+	}
+	return "\n// +build " + strings.Join(tags, ",")
+}